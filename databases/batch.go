@@ -0,0 +1,145 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BatchResult result of a command in a batch, error is set when the
+// command failed, other commands of the batch are executed anyway
+type BatchResult struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// GetMany reads keys with a single MGET, missing keys are reported as ErrRedisKeyNotFound
+func (r *RedisConnection) GetMany(keys []string) ([]*BatchResult, error) {
+	if r == nil {
+		return nil, ErrRedisInitializeFirst
+	}
+	if len(keys) == 0 {
+		return []*BatchResult{}, nil
+	}
+	values, err := r.client.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*BatchResult, 0, len(keys))
+	for idx, key := range keys {
+		result := &BatchResult{Key: key}
+		if value, ok := values[idx].(string); ok {
+			result.Value = value
+		} else {
+			result.Error = ErrRedisKeyNotFound.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SetMany writes commands with their versions in a single transaction,
+// commands with reserved keys or invalid expiration are not sent
+func (r *RedisConnection) SetMany(cmds []*RedisCommand) ([]*BatchResult, error) {
+	if r == nil {
+		return nil, ErrRedisInitializeFirst
+	}
+	ctx := context.Background()
+	results := make([]*BatchResult, len(cmds))
+	valid := 0
+	for idx, cmd := range cmds {
+		results[idx] = &BatchResult{Key: cmd.Key}
+		if err := writable(cmd.Key); err != nil {
+			results[idx].Error = err.Error()
+			continue
+		}
+		if _, _, err := cmd.expiration(); err != nil {
+			results[idx].Error = err.Error()
+			continue
+		}
+		valid++
+	}
+	if valid == 0 {
+		return results, nil
+	}
+	// versions of the batch are taken from the counter at once
+	last, err := r.client.IncrBy(ctx, versionCounter, int64(valid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	version := last - int64(valid)
+
+	sent := make([]redis.Cmder, len(cmds))
+	_, err = r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for idx, cmd := range cmds {
+			if results[idx].Error != "" {
+				continue
+			}
+			ttl, expiresAt, _ := cmd.expiration()
+			version++
+			sent[idx] = setVersioned(ctx, p, cmd, version, ttl, expiresAt)
+		}
+		return nil
+	})
+	// pipeline returns the first failed command's error, which is reported
+	// per command below, other errors mean pipeline itself failed
+	failed := false
+	for idx, c := range sent {
+		if c == nil {
+			continue
+		}
+		if c.Err() != nil {
+			failed = true
+			results[idx].Error = c.Err().Error()
+			continue
+		}
+		results[idx].Value = cmds[idx].Value
+	}
+	if err != nil && !failed {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetMany reads keys in a single pass over the store
+func (s *sS) GetMany(keys []string) ([]*BatchResult, error) {
+	if inmemory == nil {
+		return nil, ErrInmemoryInitializeFirst
+	}
+	results := make([]*BatchResult, 0, len(keys))
+	for _, key := range keys {
+		result := &BatchResult{Key: key}
+		if value, ok := load(key); ok {
+			result.Value = value.value
+		} else {
+			result.Error = ErrInmemoryKeyNotFound.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SetMany writes commands in a single pass over the store
+func (s *sS) SetMany(cmds []*InmemoryCommand) ([]*BatchResult, error) {
+	if inmemory == nil {
+		return nil, ErrInmemoryInitializeFirst
+	}
+	results := make([]*BatchResult, 0, len(cmds))
+	for _, cmd := range cmds {
+		lock := stripe(cmd.Key)
+		lock.Lock()
+		store(cmd.Key, cmd.Value, time.Time{})
+		lock.Unlock()
+		results = append(results, &BatchResult{Key: cmd.Key, Value: cmd.Value})
+	}
+	sweep()
+	return results, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisConnection_GetMany(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	r := &RedisConnection{client: db}
+
+	mock.ExpectMGet("a", "b").SetVal([]interface{}{"1", nil})
+	got, err := r.GetMany([]string{"a", "b"})
+	want := []*BatchResult{{Key: "a", Value: "1"}, {Key: "b", Error: "redis: nil"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("RedisConnection.GetMany() = %+v, %v, want %+v", got, err, want)
+	}
+
+	mock.ExpectMGet("a").SetErr(errors.New("connection refused"))
+	if _, err := r.GetMany([]string{"a"}); err == nil {
+		t.Errorf("RedisConnection.GetMany() error = nil, want connection refused")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisConnection_SetMany(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	r := &RedisConnection{client: db}
+
+	mock.ExpectIncrBy(versionCounter, 2).SetVal(7)
+	mock.ExpectTxPipeline()
+	mock.ExpectSet("a", "1", 0).SetVal("OK")
+	mock.ExpectSet(versionKey("a"), int64(6), 0).SetVal("OK")
+	// mock stops the transaction at the first failed command
+	mock.ExpectSetArgs("c", "3", redis.SetArgs{TTL: time.Minute}).SetErr(errors.New("OOM"))
+	got, err := r.SetMany([]*RedisCommand{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2", TTL: durationOf(-time.Second)},
+		{Key: "c", Value: "3", TTL: durationOf(time.Minute)},
+	})
+	want := []*BatchResult{
+		{Key: "a", Value: "1"},
+		{Key: "b", Error: ErrInvalidTTL.Error()},
+		{Key: "c", Error: "OOM"},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("RedisConnection.SetMany() = %+v, %v, want %+v", got, err, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_sS_GetManySetMany(t *testing.T) {
+	s := &sS{}
+	tearDown()
+	if _, err := s.GetMany([]string{"a"}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.GetMany() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+	if _, err := s.SetMany([]*InmemoryCommand{{Key: "a", Value: "1"}}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.SetMany() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+
+	tearUp()
+	defer tearDown()
+	set, err := s.SetMany([]*InmemoryCommand{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}})
+	if err != nil || !reflect.DeepEqual(set, []*BatchResult{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}) {
+		t.Errorf("sS.SetMany() = %+v, %v", set, err)
+	}
+	got, err := s.GetMany([]string{"b", "c"})
+	want := []*BatchResult{{Key: "b", Value: "2"}, {Key: "c", Error: ErrInmemoryKeyNotFound.Error()}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("sS.GetMany() = %+v, %v, want %+v", got, err, want)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	CacheStoreRedis    = "redis"
+	CacheStoreInmemory = "inmemory"
+
+	CacheHit  = "HIT"
+	CacheMiss = "MISS"
+)
+
+// Cache result cache of a mongodb dataset, ttl in seconds
+type Cache struct {
+	Store string `json:"store"`
+	TTL   int    `json:"ttl"`
+}
+
+func (c *Cache) validate() error {
+	if c.Store != CacheStoreRedis && c.Store != CacheStoreInmemory {
+		return ErrInvalidCache
+	}
+	if c.TTL < 1 {
+		return ErrInvalidCache
+	}
+	return nil
+}
+
+// ResultCache store of encoded fetch results
+type ResultCache interface {
+	Load(ctx context.Context, key string) ([]byte, bool, error)
+	Store(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type redisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache results cached in redis, expired by redis itself
+func NewRedisCache(r *RedisConnection) ResultCache {
+	return &redisCache{client: r.client}
+}
+
+func (c *redisCache) Load(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (c *redisCache) Store(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+type inmemoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// cacheSweepEvery interval of dropping expired entries of inmemory cache
+const cacheSweepEvery = time.Minute
+
+// inmemoryCache results cached in process, expired entries are dropped
+// when they are read and swept periodically while results are stored
+type inmemoryCache struct {
+	entries sync.Map
+	mu      sync.Mutex
+	swept   time.Time
+}
+
+// NewInmemoryCache results cached in memory of the application
+func NewInmemoryCache() ResultCache {
+	return &inmemoryCache{}
+}
+
+func (c *inmemoryCache) Load(ctx context.Context, key string) ([]byte, bool, error) {
+	v, ok := c.entries.Load(key)
+	if !ok {
+		return nil, false, nil
+	}
+	entry := v.(*inmemoryEntry)
+	if !now().Before(entry.expiresAt) {
+		c.entries.Delete(key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *inmemoryCache) Store(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.entries.Store(key, &inmemoryEntry{value: value, expiresAt: now().Add(ttl)})
+	c.sweep()
+	return nil
+}
+
+// sweep drops expired entries at most once in cacheSweepEvery, entries
+// which are never read again would stay forever otherwise
+func (c *inmemoryCache) sweep() {
+	current := now()
+	c.mu.Lock()
+	if current.Sub(c.swept) < cacheSweepEvery {
+		c.mu.Unlock()
+		return
+	}
+	c.swept = current
+	c.mu.Unlock()
+
+	c.entries.Range(func(k, v interface{}) bool {
+		if !current.Before(v.(*inmemoryEntry).expiresAt) {
+			c.entries.Delete(k)
+		}
+		return true
+	})
+}
+
+type noCacheKey struct{}
+
+// WithoutCache results of fetches with returned context are neither read
+// from cache nor reused, fresh results are still cached
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(noCacheKey{}).(bool)
+	return bypass
+}
+
+// cachedMongo caches results of Fetch, other calls go to the client
+type cachedMongo struct {
+	MongoClient
+	cache   ResultCache
+	dataset string
+	ttl     time.Duration
+}
+
+// NewCachedMongo caches fetch results of dataset for ttl, cache failures
+// are logged and results fetched from mongodb
+func NewCachedMongo(c MongoClient, cache ResultCache, dataset string, ttl time.Duration) MongoClient {
+	return &cachedMongo{MongoClient: c, cache: cache, dataset: dataset, ttl: ttl}
+}
+
+func (c *cachedMongo) Fetch(ctx context.Context, f *MongodbFilter) (*MongodbResult, error) {
+	key, err := c.key(f)
+	if err != nil {
+		return nil, err
+	}
+	if !cacheBypassed(ctx) {
+		b, ok, err := c.cache.Load(ctx, key)
+		if err != nil {
+			log.Printf("[Cache] load %s: %s", key, err.Error())
+		}
+		result := &MongodbResult{}
+		if ok && json.Unmarshal(b, result) == nil {
+			result.Cache = CacheHit
+			return result, nil
+		}
+	}
+
+	result, err := c.MongoClient.Fetch(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	if b, err := json.Marshal(result); err == nil {
+		if err := c.cache.Store(ctx, key, b, c.ttl); err != nil {
+			log.Printf("[Cache] store %s: %s", key, err.Error())
+		}
+	}
+	result.Cache = CacheMiss
+	return result, nil
+}
+
+// key cache key of filter, filters which return the same result have the
+// same key regardless of defaults given explicitly or order of keys.
+// Relative times are keyed by their expression, the time they resolve to
+// changes on every request.
+func (c *cachedMongo) key(f *MongodbFilter) (string, error) {
+	canonical := *f
+	canonical.SortBy, canonical.Order = f.SortField(), f.SortOrder()
+	if canonical.Mode == "" {
+		canonical.Mode = ModeRecords
+	}
+	if canonical.Timezone == "UTC" {
+		canonical.Timezone = ""
+	}
+	canonical.Keys = append([]string(nil), f.Keys...)
+	sort.Strings(canonical.Keys)
+	canonical.Metrics = append([]string(nil), f.Metrics...)
+	sort.Strings(canonical.Metrics)
+	if f.StartDate != nil && f.startExpr == "" {
+		t := Time(f.StartDate.Time().UTC())
+		canonical.StartDate = &t
+	} else {
+		canonical.StartDate = nil
+	}
+	if f.EndDate != nil && f.endExpr == "" {
+		t := Time(f.EndDate.Time().UTC())
+		canonical.EndDate = &t
+	} else {
+		canonical.EndDate = nil
+	}
+
+	b, err := json.Marshal(&struct {
+		*MongodbFilter
+		StartExpr string `json:"startExpr,omitempty"`
+		EndExpr   string `json:"endExpr,omitempty"`
+	}{&canonical, f.startExpr, f.endExpr})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "mongodb:cache:" + c.dataset + ":" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,163 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+)
+
+type countingMongo struct {
+	MongoClient
+	fetches int
+}
+
+func (m *countingMongo) Fetch(ctx context.Context, f *MongodbFilter) (*MongodbResult, error) {
+	m.fetches++
+	return &MongodbResult{Records: []*MongodbRecord{{Key: "a", TotalCount: m.fetches}}}, nil
+}
+
+func Test_cachedMongo_Fetch(t *testing.T) {
+	defer func() { now = time.Now }()
+	current := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	m := &countingMongo{}
+	c := NewCachedMongo(m, NewInmemoryCache(), "records", time.Minute)
+	fetch := func(ctx context.Context, f *MongodbFilter, wantCache string, wantTotal int) {
+		t.Helper()
+		got, err := c.Fetch(ctx, f)
+		if err != nil {
+			t.Errorf("cachedMongo.Fetch() error = %v", err)
+			return
+		}
+		if got.Cache != wantCache || got.Records[0].TotalCount != wantTotal {
+			t.Errorf("cachedMongo.Fetch() = %s %d, want %s %d", got.Cache, got.Records[0].TotalCount, wantCache, wantTotal)
+		}
+	}
+
+	fetch(context.Background(), &MongodbFilter{Keys: []string{"a", "b"}}, CacheMiss, 1)
+	// same filter with explicit defaults and other key order
+	fetch(context.Background(), &MongodbFilter{Keys: []string{"b", "a"}, SortBy: SortByKey, Order: OrderAsc}, CacheHit, 1)
+	fetch(context.Background(), &MongodbFilter{Keys: []string{"a"}}, CacheMiss, 2)
+	// bypassed fetch refreshes cached result
+	fetch(WithoutCache(context.Background()), &MongodbFilter{Keys: []string{"a", "b"}}, CacheMiss, 3)
+	fetch(context.Background(), &MongodbFilter{Keys: []string{"a", "b"}}, CacheHit, 3)
+
+	current = current.Add(time.Minute)
+	fetch(context.Background(), &MongodbFilter{Keys: []string{"a", "b"}}, CacheMiss, 4)
+}
+
+func Test_cachedMongo_key(t *testing.T) {
+	c := &cachedMongo{dataset: "records"}
+	date := func(s string) *Time {
+		tm, _ := time.Parse(time.RFC3339, s)
+		t := Time(tm)
+		return &t
+	}
+	tests := []struct {
+		name string
+		a, b *MongodbFilter
+		same bool
+	}{
+		{name: "defaults", a: &MongodbFilter{}, b: &MongodbFilter{SortBy: SortByKey, Order: OrderAsc, Mode: ModeRecords}, same: true},
+		{name: "metric order", a: &MongodbFilter{Metrics: []string{MetricSum, MetricMax}}, b: &MongodbFilter{Metrics: []string{MetricMax, MetricSum}}, same: true},
+		{name: "same instant", a: &MongodbFilter{StartDate: date("2023-01-02T03:00:00+03:00")}, b: &MongodbFilter{StartDate: date("2023-01-02T00:00:00Z")}, same: true},
+		{name: "other order", a: &MongodbFilter{}, b: &MongodbFilter{Order: OrderDesc}, same: false},
+		{name: "other mode", a: &MongodbFilter{}, b: &MongodbFilter{Mode: ModeCount}, same: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := c.key(tt.a)
+			b, _ := c.key(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("cachedMongo.key() = %s, %s, same %v", a, b, tt.same)
+			}
+		})
+	}
+}
+
+func Test_cachedMongo_key_relative(t *testing.T) {
+	defer func() { now = time.Now }()
+	c := &cachedMongo{dataset: "records"}
+	key := func(at time.Time, b string) string {
+		t.Helper()
+		now = func() time.Time { return at }
+		f := &MongodbFilter{}
+		if err := json.Unmarshal([]byte(b), f); err != nil {
+			t.Fatal(err)
+		}
+		k, _ := c.key(f)
+		return k
+	}
+	first := time.Date(2023, 1, 2, 10, 0, 0, 1, time.UTC)
+	later := first.Add(1500 * time.Millisecond)
+
+	if key(first, `{"startDate":"now-7d","endDate":"today"}`) != key(later, `{"startDate":"now-7d","endDate":"today"}`) {
+		t.Errorf("cachedMongo.key() differs for the same relative filter")
+	}
+	if key(first, `{"startDate":"now-7d"}`) == key(first, `{"startDate":"now-6d"}`) {
+		t.Errorf("cachedMongo.key() same for other relative filters")
+	}
+	if key(first, `{"startDate":"now-7d"}`) == key(first, `{"startDate":"now-7d","timezone":"Europe/Istanbul"}`) {
+		t.Errorf("cachedMongo.key() same for other timezones")
+	}
+	if key(first, `{"startDate":"2023-01-02T10:00:00Z"}`) == key(first, `{"startDate":"2023-01-02T10:00:01Z"}`) {
+		t.Errorf("cachedMongo.key() same for other absolute dates")
+	}
+}
+
+func Test_inmemoryCache_sweep(t *testing.T) {
+	defer func() { now = time.Now }()
+	current := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	c := NewInmemoryCache().(*inmemoryCache)
+	c.Store(context.Background(), "a", []byte("a"), time.Second)
+	c.Store(context.Background(), "b", []byte("b"), time.Hour)
+	current = current.Add(cacheSweepEvery)
+	// storing another entry drops expired a, which is never read again
+	c.Store(context.Background(), "c", []byte("c"), time.Second)
+
+	keys := []string{}
+	c.entries.Range(func(k, _ interface{}) bool {
+		keys = append(keys, k.(string))
+		return true
+	})
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Errorf("inmemoryCache entries = %v, want [b c]", keys)
+	}
+}
+
+func Test_redisCache(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	c := NewRedisCache(&RedisConnection{client: db})
+
+	mock.ExpectGet("k").RedisNil()
+	if _, ok, err := c.Load(context.Background(), "k"); ok || err != nil {
+		t.Errorf("redisCache.Load() = %v, %v, want miss", ok, err)
+	}
+	mock.ExpectSet("k", []byte("v"), time.Minute).SetVal("OK")
+	if err := c.Store(context.Background(), "k", []byte("v"), time.Minute); err != nil {
+		t.Errorf("redisCache.Store() error = %v", err)
+	}
+	mock.ExpectGet("k").SetVal("v")
+	if b, ok, err := c.Load(context.Background(), "k"); !ok || err != nil || string(b) != "v" {
+		t.Errorf("redisCache.Load() = %s, %v, %v, want v", b, ok, err)
+	}
+	mock.ExpectGet("k").SetErr(errors.New("down"))
+	if _, _, err := c.Load(context.Background(), "k"); err == nil {
+		t.Errorf("redisCache.Load() error = nil, want error")
+	}
+}
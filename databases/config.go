@@ -4,8 +4,107 @@
 
 package databases
 
+import (
+	"regexp"
+	"strings"
+)
+
 type Database struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
 	Conn string `json:"connection_string"`
+	// mongodb only, collection of records and names of record fields in it
+	Collection string  `json:"collection"`
+	Fields     *Fields `json:"fields"`
+	// mongodb only, name of the dataset in urls, database name by default
+	Dataset string `json:"dataset"`
+	// mongodb only, server side time limit of queries in milliseconds
+	MaxTimeMS int64 `json:"max_time_ms"`
+	// mongodb only, fetches slower than this are logged, in milliseconds
+	SlowQueryMS int64 `json:"slow_query_ms"`
+	// mongodb only, indexes of the collection which are created at startup
+	Indexes []*Index `json:"indexes"`
+	// mongodb only, results of fetches are cached when set
+	Cache *Cache `json:"cache"`
+	// mongodb only, daily rollup maintained in background when set
+	Rollup *Rollup `json:"rollup"`
+}
+
+var datasetPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// DatasetName returns name of the dataset which is served under /mongodb/{name}
+func (d *Database) DatasetName() string {
+	if d.Dataset == "" {
+		return d.Name
+	}
+	return d.Dataset
+}
+
+// CollectionKey identifies mongodb collection of dataset, datasets with the
+// same key share their collection and its indexes
+func (d *Database) CollectionKey() string {
+	return d.Conn + "/" + d.Name + "." + d.collectionName()
+}
+
+// collectionName collection of records, records by default
+func (d *Database) collectionName() string {
+	if d.Collection == "" {
+		return defaultCollection
+	}
+	return d.Collection
+}
+
+// validateDataset dataset names are path segments of urls
+func (d *Database) validateDataset() error {
+	if !datasetPattern.MatchString(d.DatasetName()) {
+		return ErrInvalidDatasetName
+	}
+	return nil
+}
+
+// Fields names of record fields in mongodb collection, empty names are
+// defaulted to key, count and created_at
+type Fields struct {
+	Key       string `json:"key"`
+	Count     string `json:"count"`
+	CreatedAt string `json:"created_at"`
+}
+
+var defaultFields = Fields{Key: "key", Count: "count", CreatedAt: "created_at"}
+
+const defaultCollection = "records"
+
+// withDefaults returns field names where empty names are replaced by defaults
+func (f *Fields) withDefaults() Fields {
+	if f == nil {
+		return defaultFields
+	}
+	fields := *f
+	if fields.Key == "" {
+		fields.Key = defaultFields.Key
+	}
+	if fields.Count == "" {
+		fields.Count = defaultFields.Count
+	}
+	if fields.CreatedAt == "" {
+		fields.CreatedAt = defaultFields.CreatedAt
+	}
+	return fields
+}
+
+// validate field names must be plain document fields, not operators or paths
+// of other field
+func (f Fields) validate() error {
+	names := []string{f.Key, f.Count, f.CreatedAt}
+	for i, name := range names {
+		if strings.HasPrefix(name, "$") || strings.Contains(name, ".") || name == "_id" {
+			return ErrInvalidFieldName
+		}
+		for _, other := range names[i+1:] {
+			if name == other {
+				return ErrInvalidFieldName
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,87 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFields_withDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields *Fields
+		want   Fields
+	}{
+		{
+			name:   "nil",
+			fields: nil,
+			want:   Fields{Key: "key", Count: "count", CreatedAt: "created_at"},
+		},
+		{
+			name:   "partial",
+			fields: &Fields{Count: "value"},
+			want:   Fields{Key: "key", Count: "value", CreatedAt: "created_at"},
+		},
+		{
+			name:   "all",
+			fields: &Fields{Key: "event", Count: "value", CreatedAt: "ts"},
+			want:   Fields{Key: "event", Count: "value", CreatedAt: "ts"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fields.withDefaults(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fields.withDefaults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFields_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  Fields
+		wantErr bool
+	}{
+		{name: "defaults", fields: defaultFields, wantErr: false},
+		{name: "operator", fields: Fields{Key: "$key", Count: "count", CreatedAt: "created_at"}, wantErr: true},
+		{name: "path", fields: Fields{Key: "meta.key", Count: "count", CreatedAt: "created_at"}, wantErr: true},
+		{name: "id", fields: Fields{Key: "_id", Count: "count", CreatedAt: "created_at"}, wantErr: true},
+		{name: "duplicate", fields: Fields{Key: "key", Count: "key", CreatedAt: "created_at"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fields.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Fields.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatabase_DatasetName(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      *Database
+		want    string
+		wantErr bool
+	}{
+		{name: "database name", db: &Database{Name: "test"}, want: "test", wantErr: false},
+		{name: "dataset", db: &Database{Name: "test", Dataset: "orders"}, want: "orders", wantErr: false},
+		{name: "invalid", db: &Database{Name: "test", Dataset: "orders/v2"}, want: "orders/v2", wantErr: true},
+		{name: "empty", db: &Database{}, want: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.db.DatasetName(); got != tt.want {
+				t.Errorf("Database.DatasetName() = %v, want %v", got, tt.want)
+			}
+			if err := tt.db.validateDataset(); (err != nil) != tt.wantErr {
+				t.Errorf("Database.validateDataset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
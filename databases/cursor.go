@@ -0,0 +1,58 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Cursor position of the last record returned to the client, clients
+// receive it as an opaque token and send it back to fetch the next page
+type Cursor struct {
+	ID     string      `json:"id"`
+	SortBy string      `json:"sortBy,omitempty"`
+	Order  string      `json:"order,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+// EncodeCursor encode cursor as url safe opaque token
+func EncodeCursor(c *Cursor) string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decode token which created by EncodeCursor
+func DecodeCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Matches reports whether cursor created for the same sort with the filter,
+// cursors created before sorting was configurable are sorted by key
+func (c *Cursor) Matches(f *MongodbFilter) bool {
+	sortBy, order := c.SortBy, c.Order
+	if sortBy == "" {
+		sortBy = SortByKey
+	}
+	if order == "" {
+		order = OrderAsc
+	}
+	return sortBy == f.SortField() && order == f.SortOrder()
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	type args struct {
+		token string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Cursor
+		wantErr bool
+	}{
+		{
+			name:    "encoded",
+			args:    args{token: EncodeCursor(&Cursor{ID: "key"})},
+			want:    &Cursor{ID: "key"},
+			wantErr: false,
+		},
+		{
+			name:    "not base64",
+			args:    args{token: "!!"},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			args:    args{token: "bm90anNvbg"},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "empty id",
+			args:    args{token: EncodeCursor(&Cursor{})},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.args.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursor_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor *Cursor
+		filter *MongodbFilter
+		want   bool
+	}{
+		{
+			name:   "default sort",
+			cursor: &Cursor{ID: "a"},
+			filter: &MongodbFilter{},
+			want:   true,
+		},
+		{
+			name:   "same sort",
+			cursor: &Cursor{ID: "a", SortBy: SortByTotalCount, Order: OrderDesc},
+			filter: &MongodbFilter{SortBy: SortByTotalCount, Order: OrderDesc},
+			want:   true,
+		},
+		{
+			name:   "different order",
+			cursor: &Cursor{ID: "a", SortBy: SortByTotalCount, Order: OrderDesc},
+			filter: &MongodbFilter{SortBy: SortByTotalCount},
+			want:   false,
+		},
+		{
+			name:   "different field",
+			cursor: &Cursor{ID: "a"},
+			filter: &MongodbFilter{SortBy: SortByCreatedAt},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.Matches(tt.filter); got != tt.want {
+				t.Errorf("Cursor.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
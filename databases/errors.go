@@ -7,5 +7,30 @@ import "errors"
 
 var ErrConfigParameterMissing = errors.New("configuration value is nil")
 var ErrInmemoryKeyNotFound = errors.New("inmemory: nil")
+var ErrRedisKeyNotFound = errors.New("redis: nil")
+var ErrRedisInitializeFirst = errors.New("redis: initialize redis first")
 var ErrInmemoryOperationFailed = errors.New("inmemory: operation failed")
 var ErrInmemoryInitializeFirst = errors.New("inmemory: initialize inmemory first")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrCursorMismatch = errors.New("cursor does not match sort")
+var ErrInvalidKeyPattern = errors.New("invalid keyPattern")
+var ErrKeyPatternTooComplex = errors.New("keyPattern is too complex")
+var ErrInvalidTimezone = errors.New("invalid timezone")
+var ErrInvalidRelativeTime = errors.New("invalid relative time expression")
+var ErrInvalidFieldName = errors.New("mongodb: invalid field name")
+var ErrInvalidDatasetName = errors.New("mongodb: invalid dataset name")
+var ErrInvalidMaxTime = errors.New("mongodb: invalid max_time_ms")
+var ErrInvalidSlowQuery = errors.New("mongodb: invalid slow_query_ms")
+var ErrInvalidIndex = errors.New("mongodb: invalid index")
+var ErrInvalidCache = errors.New("mongodb: invalid cache")
+var ErrInvalidRollup = errors.New("mongodb: invalid rollup")
+var ErrInvalidResumeToken = errors.New("mongodb: invalid resume token")
+var ErrInvalidTTL = errors.New("redis: invalid ttl")
+var ErrRedisTTLConflict = errors.New("redis: ttl and expiresAt can not be used together")
+var ErrInvalidKeysCursor = errors.New("invalid keys cursor")
+var ErrInvalidIncrement = errors.New("invalid increment")
+var ErrNotANumber = errors.New("value is not a number")
+var ErrIncrementOverflow = errors.New("increment would overflow")
+var ErrInmemoryTTLUnsupported = errors.New("inmemory: ttl is not supported")
+var ErrPreconditionFailed = errors.New("precondition failed")
+var ErrReservedKey = errors.New("redis: key is reserved")
@@ -0,0 +1,184 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxWatchRetries attempts of conditional redis write when watched key is
+// changed by another client before transaction is executed
+const maxWatchRetries = 3
+
+// versionCounter redis key which versions of all keys are taken from, so a
+// key deleted and written again never gets a version it had before
+const versionCounter = "__version"
+
+// versionPrefix prefix of redis keys which store version of a key, they are
+// written and expire together with the key
+const versionPrefix = "__version:"
+
+func versionKey(key string) string {
+	return versionPrefix + key
+}
+
+// isVersionKey reports whether redis key is used for versioning of other keys
+func isVersionKey(key string) bool {
+	return key == versionCounter || strings.HasPrefix(key, versionPrefix)
+}
+
+// writable rejects keys used for versioning, clients writing them could
+// break the counter or forge versions of other keys
+func writable(key string) error {
+	if isVersionKey(key) {
+		return ErrReservedKey
+	}
+	return nil
+}
+
+// inmemoryVersions counter which versions of inmemory values are taken from
+var inmemoryVersions uint64
+
+// ETag strong entity tag of a version, every write gets a new version so a
+// value changed and written back has another tag than it had before
+func ETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// Condition preconditions of a conditional write, tags are compared with
+// ETag of current value, * matches any existing value
+type Condition struct {
+	IfMatch     []string
+	IfNoneMatch []string
+}
+
+// holds evaluates condition like http does, If-Match compares tags strongly
+// and If-None-Match weakly
+func (c *Condition) holds(version uint64, exists bool) bool {
+	tag := ETag(version)
+	if len(c.IfMatch) > 0 {
+		if !exists {
+			return false
+		}
+		matched := false
+		for _, t := range c.IfMatch {
+			if t == "*" || t == tag {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, t := range c.IfNoneMatch {
+		if t == "*" && exists {
+			return false
+		}
+		if exists && strings.TrimPrefix(t, "W/") == tag {
+			return false
+		}
+	}
+	return true
+}
+
+// setVersioned queues write of value and its version with the same expiry,
+// zero ttl and expiresAt means key never expires
+func setVersioned(ctx context.Context, p redis.Pipeliner, cmd *RedisCommand, version int64, ttl time.Duration, expiresAt time.Time) *redis.StatusCmd {
+	if ttl == 0 && expiresAt.IsZero() {
+		s := p.Set(ctx, cmd.Key, cmd.Value, 0)
+		p.Set(ctx, versionKey(cmd.Key), version, 0)
+		return s
+	}
+	args := redis.SetArgs{TTL: ttl, ExpireAt: expiresAt}
+	s := p.SetArgs(ctx, cmd.Key, cmd.Value, args)
+	p.SetArgs(ctx, versionKey(cmd.Key), version, args)
+	return s
+}
+
+// SetIf writes command when condition holds for version of current value,
+// key and its version are watched so writes between the check and the set
+// abort the transaction. Version of cmd is set to the written version.
+func (r *RedisConnection) SetIf(cmd *RedisCommand, cond *Condition) error {
+	if r == nil {
+		return ErrRedisInitializeFirst
+	}
+	if err := writable(cmd.Key); err != nil {
+		return err
+	}
+	ttl, expiresAt, err := cmd.expiration()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for i := 0; i < maxWatchRetries; i++ {
+		var version int64
+		err = r.client.Watch(ctx, func(tx *redis.Tx) error {
+			exists, err := tx.Exists(ctx, cmd.Key).Result()
+			if err != nil {
+				return err
+			}
+			current, err := tx.Get(ctx, versionKey(cmd.Key)).Uint64()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if !cond.holds(current, exists > 0) {
+				return ErrPreconditionFailed
+			}
+			// counter is not watched, versions skipped by aborted writes are lost
+			if version, err = tx.Incr(ctx, versionCounter).Result(); err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				setVersioned(ctx, p, cmd, version, ttl, expiresAt)
+				return nil
+			})
+			return err
+		}, cmd.Key, versionKey(cmd.Key))
+		if err == nil {
+			cmd.Version = uint64(version)
+			return nil
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	// key kept changing, condition of the caller is stale
+	return ErrPreconditionFailed
+}
+
+// SetIf writes command when condition holds for version of current value,
+// checked and written under stripe lock of key
+func (s *sS) SetIf(cmd *InmemoryCommand, cond *Condition) error {
+	if inmemory == nil {
+		return ErrInmemoryInitializeFirst
+	}
+	lock := stripe(cmd.Key)
+	lock.Lock()
+	var version uint64
+	current, ok := load(cmd.Key)
+	if ok {
+		version = current.version
+	}
+	if !cond.holds(version, ok) {
+		lock.Unlock()
+		return ErrPreconditionFailed
+	}
+	cmd.Version = store(cmd.Key, cmd.Value, time.Time{})
+	lock.Unlock()
+	sweep()
+
+	return nil
+}
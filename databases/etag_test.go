@@ -0,0 +1,172 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCondition_holds(t *testing.T) {
+	tag := ETag(1)
+	tests := []struct {
+		name   string
+		cond   *Condition
+		exists bool
+		want   bool
+	}{
+		{name: "no condition", cond: &Condition{}, exists: false, want: true},
+		{name: "if-match / same", cond: &Condition{IfMatch: []string{`"x"`, tag}}, exists: true, want: true},
+		{name: "if-match / changed", cond: &Condition{IfMatch: []string{`"x"`}}, exists: true, want: false},
+		{name: "if-match / weak", cond: &Condition{IfMatch: []string{"W/" + tag}}, exists: true, want: false},
+		{name: "if-match / any / missing", cond: &Condition{IfMatch: []string{"*"}}, exists: false, want: false},
+		{name: "if-match / any", cond: &Condition{IfMatch: []string{"*"}}, exists: true, want: true},
+		{name: "if-none-match / any / missing", cond: &Condition{IfNoneMatch: []string{"*"}}, exists: false, want: true},
+		{name: "if-none-match / any", cond: &Condition{IfNoneMatch: []string{"*"}}, exists: true, want: false},
+		{name: "if-none-match / weak same", cond: &Condition{IfNoneMatch: []string{"W/" + tag}}, exists: true, want: false},
+		{name: "if-none-match / changed", cond: &Condition{IfNoneMatch: []string{`"x"`}}, exists: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var version uint64
+			if tt.exists {
+				version = 1
+			}
+			if got := tt.cond.holds(version, tt.exists); got != tt.want {
+				t.Errorf("Condition.holds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisConnection_SetIf(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	r := &RedisConnection{client: db}
+
+	mock.ExpectWatch("k", versionKey("k"))
+	mock.ExpectExists("k").SetVal(1)
+	mock.ExpectGet(versionKey("k")).SetVal("1")
+	mock.ExpectIncr(versionCounter).SetVal(2)
+	mock.ExpectTxPipeline()
+	mock.ExpectSetArgs("k", "v2", redis.SetArgs{TTL: time.Minute}).SetVal("OK")
+	mock.ExpectSetArgs(versionKey("k"), int64(2), redis.SetArgs{TTL: time.Minute}).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+	cmd := &RedisCommand{Key: "k", Value: "v2", TTL: durationOf(time.Minute)}
+	if err := r.SetIf(cmd, &Condition{IfMatch: []string{ETag(1)}}); err != nil {
+		t.Errorf("RedisConnection.SetIf() error = %v", err)
+	}
+	if cmd.Version != 2 {
+		t.Errorf("RedisConnection.SetIf() version = %d, want 2", cmd.Version)
+	}
+
+	mock.ExpectWatch("k", versionKey("k"))
+	mock.ExpectExists("k").SetVal(1)
+	mock.ExpectGet(versionKey("k")).SetVal("2")
+	if err := r.SetIf(cmd, &Condition{IfMatch: []string{ETag(1)}}); err != ErrPreconditionFailed {
+		t.Errorf("RedisConnection.SetIf() error = %v, want %v", err, ErrPreconditionFailed)
+	}
+
+	mock.ExpectWatch("k", versionKey("k"))
+	mock.ExpectExists("k").SetVal(0)
+	mock.ExpectGet(versionKey("k")).RedisNil()
+	mock.ExpectIncr(versionCounter).SetVal(3)
+	mock.ExpectTxPipeline()
+	mock.ExpectSet("k", "v1", 0).SetVal("OK")
+	mock.ExpectSet(versionKey("k"), int64(3), 0).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+	if err := r.SetIf(&RedisCommand{Key: "k", Value: "v1"}, &Condition{IfNoneMatch: []string{"*"}}); err != nil {
+		t.Errorf("RedisConnection.SetIf() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_sS_SetIf(t *testing.T) {
+	s := &sS{}
+	tearDown()
+	if err := s.SetIf(&InmemoryCommand{Key: "k", Value: "v"}, &Condition{}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.SetIf() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+
+	tearUp()
+	defer tearDown()
+	steps := []struct {
+		name  string
+		value string
+		cond  *Condition
+		want  error
+	}{
+		{name: "create only", value: "v1", cond: &Condition{IfNoneMatch: []string{"*"}}},
+		{name: "create only / exists", value: "v2", cond: &Condition{IfNoneMatch: []string{"*"}}, want: ErrPreconditionFailed},
+		{name: "update / stale", value: "v2", cond: &Condition{IfMatch: []string{ETag(0)}}, want: ErrPreconditionFailed},
+		{name: "update", value: "v2", cond: &Condition{IfMatch: []string{"*"}}},
+	}
+	for _, step := range steps {
+		if err := s.SetIf(&InmemoryCommand{Key: "k", Value: step.value}, step.cond); err != step.want {
+			t.Errorf("%s: sS.SetIf() error = %v, want %v", step.name, err, step.want)
+		}
+	}
+	if got, _ := s.Get(&InmemoryCommand{Key: "k"}); got.Value != "v2" {
+		t.Errorf("sS.SetIf() stored %s, want v2", got.Value)
+	}
+}
+
+func Test_sS_SetIf_aba(t *testing.T) {
+	s := &sS{}
+	tearUp()
+	defer tearDown()
+
+	// value changed and written back keeps no tag it had before
+	first := &InmemoryCommand{Key: "k", Value: "a"}
+	s.Set(first)
+	s.Set(&InmemoryCommand{Key: "k", Value: "b"})
+	s.Set(&InmemoryCommand{Key: "k", Value: "a"})
+	stale := &Condition{IfMatch: []string{ETag(first.Version)}}
+	if err := s.SetIf(&InmemoryCommand{Key: "k", Value: "c"}, stale); err != ErrPreconditionFailed {
+		t.Errorf("sS.SetIf() error = %v, want %v", err, ErrPreconditionFailed)
+	}
+
+	current, _ := s.Get(&InmemoryCommand{Key: "k"})
+	cmd := &InmemoryCommand{Key: "k", Value: "c"}
+	if err := s.SetIf(cmd, &Condition{IfMatch: []string{ETag(current.Version)}}); err != nil {
+		t.Errorf("sS.SetIf() error = %v", err)
+	}
+	if cmd.Version <= current.Version {
+		t.Errorf("sS.SetIf() version = %d, want after %d", cmd.Version, current.Version)
+	}
+}
+
+func TestRedisConnection_reservedKeys(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	r := &RedisConnection{client: db}
+
+	for _, key := range []string{versionCounter, versionKey("k")} {
+		if err := r.Set(&RedisCommand{Key: key, Value: "x"}); err != ErrReservedKey {
+			t.Errorf("RedisConnection.Set(%s) error = %v, want %v", key, err, ErrReservedKey)
+		}
+		if err := r.SetIf(&RedisCommand{Key: key, Value: "x"}, &Condition{}); err != ErrReservedKey {
+			t.Errorf("RedisConnection.SetIf(%s) error = %v, want %v", key, err, ErrReservedKey)
+		}
+		if _, err := r.Delete(&RedisCommand{Key: key}); err != ErrReservedKey {
+			t.Errorf("RedisConnection.Delete(%s) error = %v, want %v", key, err, ErrReservedKey)
+		}
+		if _, err := r.Incr(&IncrCommand{Key: key}); err != ErrReservedKey {
+			t.Errorf("RedisConnection.Incr(%s) error = %v, want %v", key, err, ErrReservedKey)
+		}
+		got, err := r.SetMany([]*RedisCommand{{Key: key, Value: "x"}})
+		if err != nil || len(got) != 1 || got[0].Error != ErrReservedKey.Error() {
+			t.Errorf("RedisConnection.SetMany(%s) = %+v, %v, want %v", key, got, err, ErrReservedKey)
+		}
+	}
+	// nothing is sent to redis
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
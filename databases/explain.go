@@ -0,0 +1,95 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongodbExplain pipeline generated for a filter and server's explain output
+// of it, both as relaxed extended json
+type MongodbExplain struct {
+	Pipeline json.RawMessage `json:"pipeline"`
+	Explain  json.RawMessage `json:"explain"`
+}
+
+// Explain runs explain command of the aggregation Fetch would run for the
+// filter, query is planned but not executed
+func (c *mClient) Explain(ctx context.Context, f *MongodbFilter) (*MongodbExplain, error) {
+	pipeline, err := c.pipeline(f)
+	if err != nil {
+		return nil, err
+	}
+	p, err := marshalPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := bson.D{
+		{"explain", bson.D{
+			{"aggregate", c.collection.Name()},
+			{"pipeline", pipeline},
+			{"cursor", bson.D{}},
+		}},
+		{"verbosity", "queryPlanner"},
+	}
+	if c.maxTime > 0 {
+		cmd = append(cmd, bson.E{"maxTimeMS", c.maxTime.Milliseconds()})
+	}
+	raw, err := c.database.RunCommand(ctx, cmd).DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	explain, err := bson.MarshalExtJSON(raw, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return &MongodbExplain{Pipeline: p, Explain: explain}, nil
+}
+
+// marshalPipeline pipeline as json array of extended json stages
+func marshalPipeline(pipeline mongo.Pipeline) (json.RawMessage, error) {
+	stages := make([]json.RawMessage, 0, len(pipeline))
+	for _, stage := range pipeline {
+		b, err := bson.MarshalExtJSON(stage, false, false)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, b)
+	}
+	return json.Marshal(stages)
+}
+
+// logSlowQuery logs pipeline of fetches which took longer than slow query
+// threshold, failed ones included since timeouts are the slowest of all
+func (c *mClient) logSlowQuery(pipeline mongo.Pipeline, elapsed time.Duration, result *MongodbResult, err error) {
+	if c.slowQuery <= 0 || elapsed < c.slowQuery {
+		return
+	}
+	p, mErr := marshalPipeline(pipeline)
+	if mErr != nil {
+		p = json.RawMessage("null")
+	}
+	if err != nil {
+		log.Printf("[SlowQuery] %s failed after %s: %s, pipeline: %s", c.collection.Name(), elapsed, err.Error(), p)
+		return
+	}
+	log.Printf("[SlowQuery] %s took %s, %d records, pipeline: %s", c.collection.Name(), elapsed, result.size(), p)
+}
+
+// size number of records in result, totals are a single record
+func (r *MongodbResult) size() int {
+	if r.Count != nil || r.Summary != nil {
+		return 1
+	}
+	return len(r.Records)
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_mClient_Explain(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.RunOpts("explain", mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{"queryPlanner", bson.D{{"namespace", "test.records"}}}))
+		c := &mClient{
+			client:     mt.Client,
+			database:   mt.DB,
+			collection: mt.Coll,
+		}
+
+		got, err := c.Explain(context.Background(), &MongodbFilter{Keys: []string{"a"}})
+		if err != nil {
+			t.Errorf("mClient.Explain() error = %v", err)
+			return
+		}
+		wantPipeline := `[{"$match":{"$and":[{},{},{"key":{"$in":["a"]}}]}},` +
+			`{"$group":{"_id":"$key","totalCount":{"$sum":"$count"},"createdAt":{"$min":"$created_at"}}},` +
+			`{"$set":{"createdAt":{"$dateToString":{"date":"$createdAt"}}}},{"$sort":{"_id":1}}]`
+		if string(got.Pipeline) != wantPipeline {
+			t.Errorf("mClient.Explain() pipeline = %s, want %s", got.Pipeline, wantPipeline)
+		}
+		if !strings.Contains(string(got.Explain), `"namespace":"test.records"`) {
+			t.Errorf("mClient.Explain() explain = %s", got.Explain)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "explain" {
+			t.Errorf("mClient.Explain() command = %v, want explain", started)
+		}
+	})
+}
+
+func Test_mClient_logSlowQuery(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	pipeline := mongo.Pipeline{{{"$match", bson.D{}}}}
+	tests := []struct {
+		name      string
+		slowQuery time.Duration
+		elapsed   time.Duration
+		err       error
+		want      string
+	}{
+		{name: "disabled", slowQuery: 0, elapsed: time.Minute, want: ""},
+		{name: "fast", slowQuery: time.Second, elapsed: time.Millisecond, want: ""},
+		{
+			name:      "slow",
+			slowQuery: time.Second,
+			elapsed:   2 * time.Second,
+			want:      `[SlowQuery] records took 2s, 1 records, pipeline: [{"$match":{}}]`,
+		},
+		{
+			name:      "slow failure",
+			slowQuery: time.Second,
+			elapsed:   2 * time.Second,
+			err:       context.DeadlineExceeded,
+			want:      `[SlowQuery] records failed after 2s: context deadline exceeded, pipeline: [{"$match":{}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			c := &mClient{collection: (&mongo.Client{}).Database("test").Collection("records"), slowQuery: tt.slowQuery}
+			c.logSlowQuery(pipeline, tt.elapsed, &MongodbResult{Records: []*MongodbRecord{{Key: "a"}}}, tt.err)
+			if got := buf.String(); !strings.Contains(got, tt.want) || (tt.want == "" && got != "") {
+				t.Errorf("mClient.logSlowQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
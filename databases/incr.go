@@ -0,0 +1,179 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IncrCommand increments value of key by By, which is an integer or float
+// and 1 when omitted, missing keys start from zero and expire after TTL
+// when given, TTL of existing keys is not changed
+type IncrCommand struct {
+	Key string      `json:"key"`
+	By  json.Number `json:"by,omitempty"`
+	TTL *Duration   `json:"ttl,omitempty"`
+}
+
+// IncrResult value of key after increment
+type IncrResult struct {
+	Key   string      `json:"key"`
+	Value json.Number `json:"value"`
+}
+
+// amount parses By, float is true when increment is not an integer
+func (cmd *IncrCommand) amount() (by int64, byFloat float64, float bool, err error) {
+	if cmd.By == "" {
+		return 1, 0, false, nil
+	}
+	if by, err := strconv.ParseInt(string(cmd.By), 10, 64); err == nil {
+		return by, 0, false, nil
+	}
+	byFloat, err = strconv.ParseFloat(string(cmd.By), 64)
+	if err != nil || math.IsNaN(byFloat) || math.IsInf(byFloat, 0) {
+		return 0, 0, false, ErrInvalidIncrement
+	}
+	return 0, byFloat, true, nil
+}
+
+// ttl validates TTL of command, zero means key never expires
+func (cmd *IncrCommand) ttl() (time.Duration, error) {
+	if cmd.TTL == nil {
+		return 0, nil
+	}
+	ttl := time.Duration(*cmd.TTL)
+	if ttl < time.Millisecond {
+		return 0, ErrInvalidTTL
+	}
+	return ttl, nil
+}
+
+// incrScript increments and sets expiry of created keys atomically, the
+// value gets a new version which expires together with the key
+const incrScript = `
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local value
+if ARGV[2] == 'float' then
+	value = redis.call('INCRBYFLOAT', KEYS[1], ARGV[1])
+else
+	value = redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+if created and tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+local version = redis.call('INCR', KEYS[3])
+local pttl = redis.call('PTTL', KEYS[1])
+if pttl > 0 then
+	redis.call('SET', KEYS[2], version, 'PX', pttl)
+else
+	redis.call('SET', KEYS[2], version)
+end
+return value
+`
+
+// Incr increments key with INCRBY or INCRBYFLOAT in a script, so expiry of
+// created key and version of the value are set in the same step
+func (r *RedisConnection) Incr(cmd *IncrCommand) (*IncrResult, error) {
+	if r == nil {
+		return nil, ErrRedisInitializeFirst
+	}
+	if err := writable(cmd.Key); err != nil {
+		return nil, err
+	}
+	by, byFloat, float, err := cmd.amount()
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := cmd.ttl()
+	if err != nil {
+		return nil, err
+	}
+
+	kind, amount := "int", strconv.FormatInt(by, 10)
+	if float {
+		kind, amount = "float", strconv.FormatFloat(byFloat, 'f', -1, 64)
+	}
+	value, err := r.client.Eval(context.Background(), incrScript, []string{cmd.Key, versionKey(cmd.Key), versionCounter},
+		amount, kind, ttl.Milliseconds()).Result()
+	if err != nil {
+		return nil, incrError(err)
+	}
+
+	return &IncrResult{Key: cmd.Key, Value: json.Number(fmt.Sprint(value))}, nil
+}
+
+// incrError translates redis errors of values which can't be incremented
+func incrError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not an integer"), strings.Contains(msg, "not a valid float"):
+		return ErrNotANumber
+	case strings.Contains(msg, "overflow"):
+		return ErrIncrementOverflow
+	}
+	return err
+}
+
+// Incr increments key under its stripe lock, values are stored as strings
+// like redis does, so counters can be read by Get. Created key expires after
+// TTL, expiry of existing key is kept.
+func (s *sS) Incr(cmd *IncrCommand) (*IncrResult, error) {
+	if inmemory == nil {
+		return nil, ErrInmemoryInitializeFirst
+	}
+	by, byFloat, float, err := cmd.amount()
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := cmd.ttl()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := stripe(cmd.Key)
+	lock.Lock()
+	defer sweep()
+	defer lock.Unlock()
+
+	current := "0"
+	var expiresAt time.Time
+	if value, ok := load(cmd.Key); ok {
+		current, expiresAt = value.value, value.expiresAt
+	} else if ttl > 0 {
+		expiresAt = now().Add(ttl)
+	}
+
+	var value string
+	if float {
+		n, err := strconv.ParseFloat(current, 64)
+		if err != nil {
+			return nil, ErrNotANumber
+		}
+		n += byFloat
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, ErrIncrementOverflow
+		}
+		value = strconv.FormatFloat(n, 'f', -1, 64)
+	} else {
+		n, err := strconv.ParseInt(current, 10, 64)
+		if err != nil {
+			return nil, ErrNotANumber
+		}
+		if (by > 0 && n > math.MaxInt64-by) || (by < 0 && n < math.MinInt64-by) {
+			return nil, ErrIncrementOverflow
+		}
+		value = strconv.FormatInt(n+by, 10)
+	}
+	store(cmd.Key, value, expiresAt)
+
+	return &IncrResult{Key: cmd.Key, Value: json.Number(value)}, nil
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+)
+
+func TestRedisConnection_Incr(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *IncrCommand
+		expect  func(redismock.ClientMock)
+		want    string
+		wantErr error
+	}{
+		{
+			name: "default increment",
+			cmd:  &IncrCommand{Key: "hits"},
+			expect: func(mock redismock.ClientMock) {
+				mock.ExpectEval(incrScript, []string{"hits", versionKey("hits"), versionCounter}, "1", "int", int64(0)).SetVal(int64(1))
+			},
+			want: "1",
+		},
+		{
+			name: "float increment with ttl",
+			cmd:  &IncrCommand{Key: "rate", By: "0.5", TTL: durationOf(time.Minute)},
+			expect: func(mock redismock.ClientMock) {
+				mock.ExpectEval(incrScript, []string{"rate", versionKey("rate"), versionCounter}, "0.5", "float", int64(60000)).SetVal("2.5")
+			},
+			want: "2.5",
+		},
+		{
+			name:    "invalid increment",
+			cmd:     &IncrCommand{Key: "hits", By: "1e400"},
+			expect:  func(mock redismock.ClientMock) {},
+			wantErr: ErrInvalidIncrement,
+		},
+		{
+			name:    "invalid ttl",
+			cmd:     &IncrCommand{Key: "hits", TTL: durationOf(0)},
+			expect:  func(mock redismock.ClientMock) {},
+			wantErr: ErrInvalidTTL,
+		},
+		{
+			name: "not a number",
+			cmd:  &IncrCommand{Key: "name", By: "-2"},
+			expect: func(mock redismock.ClientMock) {
+				mock.ExpectEval(incrScript, []string{"name", versionKey("name"), versionCounter}, "-2", "int", int64(0)).
+					SetErr(errors.New("ERR value is not an integer or out of range script: ..."))
+			},
+			wantErr: ErrNotANumber,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := redismock.NewClientMock()
+			tt.expect(mock)
+			r := &RedisConnection{client: db}
+			got, err := r.Incr(tt.cmd)
+			if err != tt.wantErr {
+				t.Errorf("RedisConnection.Incr() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && (got.Key != tt.cmd.Key || string(got.Value) != tt.want) {
+				t.Errorf("RedisConnection.Incr() = %+v, want %s", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func Test_sS_Incr(t *testing.T) {
+	s := &sS{}
+	tearDown()
+	if _, err := s.Incr(&IncrCommand{Key: "hits"}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.Incr() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+
+	tearUp()
+	defer tearDown()
+	store("name", "john", time.Time{})
+	store("rate", "1.5", time.Time{})
+	store("max", strconv.FormatInt(1<<63-1, 10), time.Time{})
+	tests := []struct {
+		name    string
+		cmd     *IncrCommand
+		want    string
+		wantErr error
+	}{
+		{name: "create", cmd: &IncrCommand{Key: "hits"}, want: "1"},
+		{name: "increment", cmd: &IncrCommand{Key: "hits", By: "10"}, want: "11"},
+		{name: "decrement", cmd: &IncrCommand{Key: "hits", By: "-12"}, want: "-1"},
+		{name: "float", cmd: &IncrCommand{Key: "rate", By: "0.25"}, want: "1.75"},
+		{name: "int on float", cmd: &IncrCommand{Key: "rate", By: "1"}, wantErr: ErrNotANumber},
+		{name: "not a number", cmd: &IncrCommand{Key: "name"}, wantErr: ErrNotANumber},
+		{name: "overflow", cmd: &IncrCommand{Key: "max"}, wantErr: ErrIncrementOverflow},
+		{name: "invalid increment", cmd: &IncrCommand{Key: "hits", By: "abc"}, wantErr: ErrInvalidIncrement},
+		{name: "invalid ttl", cmd: &IncrCommand{Key: "hits", TTL: durationOf(0)}, wantErr: ErrInvalidTTL},
+	}
+	for _, tt := range tests {
+		got, err := s.Incr(tt.cmd)
+		if err != tt.wantErr {
+			t.Errorf("%s: sS.Incr() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got.Value) != tt.want {
+			t.Errorf("%s: sS.Incr() = %s, want %s", tt.name, got.Value, tt.want)
+		}
+	}
+
+	// concurrent workers don't lose increments
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				s.Incr(&IncrCommand{Key: "concurrent"})
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := s.Get(&InmemoryCommand{Key: "concurrent"}); got.Value != "1000" {
+		t.Errorf("sS.Incr() concurrent = %s, want 1000", got.Value)
+	}
+}
+
+func Test_sS_Incr_ttl(t *testing.T) {
+	defer func() { now = time.Now }()
+	current := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	tearUp()
+	defer tearDown()
+	s := &sS{}
+
+	incr := func(want string) {
+		t.Helper()
+		got, err := s.Incr(&IncrCommand{Key: "hits", TTL: durationOf(time.Minute)})
+		if err != nil || string(got.Value) != want {
+			t.Errorf("sS.Incr() = %+v, %v, want %s", got, err, want)
+		}
+	}
+	incr("1")
+	// ttl of existing key is not extended
+	current = current.Add(30 * time.Second)
+	incr("2")
+	current = current.Add(30 * time.Second)
+	if _, err := s.Get(&InmemoryCommand{Key: "hits"}); err != ErrInmemoryKeyNotFound {
+		t.Errorf("sS.Get() error = %v, want %v", err, ErrInmemoryKeyNotFound)
+	}
+	if ok, _ := s.Exists(&InmemoryCommand{Key: "hits"}); ok {
+		t.Errorf("sS.Exists() = true, want expired")
+	}
+	if keys, _ := s.Keys(&KeysCommand{}); len(keys.Keys) != 0 {
+		t.Errorf("sS.Keys() = %v, want expired", keys.Keys)
+	}
+	// expired key is created again
+	incr("1")
+}
+
+func Test_sweep(t *testing.T) {
+	defer func() { now = time.Now }()
+	current := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	tearUp()
+	defer tearDown()
+	sweptAt = time.Time{}
+
+	store("expiring", "1", current.Add(time.Second))
+	store("kept", "1", time.Time{})
+	current = current.Add(inmemorySweepEvery)
+	sweep()
+
+	if _, ok := inmemory.Load("expiring"); ok {
+		t.Errorf("sweep() kept expired key")
+	}
+	if _, ok := inmemory.Load("kept"); !ok {
+		t.Errorf("sweep() dropped key without expiry")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Index declared index of records collection, keys are field names where
+// descending ones are prefixed with "-". Name defaults to the name mongodb
+// generates, e.g. created_at_1_key_-1
+type Index struct {
+	Name   string   `json:"name"`
+	Keys   []string `json:"keys"`
+	Unique bool     `json:"unique"`
+}
+
+// IndexReport difference between declared and existing indexes
+type IndexReport struct {
+	// declared but not existing ones, created unless checking only
+	Missing []string
+	Created []string
+	// existing ones with the same name but other keys or options
+	Changed []string
+	// existing but not declared ones, never dropped
+	Extra []string
+}
+
+// Drift reports whether indexes of collection differ from declared ones
+func (r *IndexReport) Drift() bool {
+	return len(r.Missing) > len(r.Created) || len(r.Changed) > 0 || len(r.Extra) > 0
+}
+
+// defaultIndexName mongodb's name for index keys
+func defaultIndexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", k.Key, k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// keys index keys document
+func (i *Index) keys() bson.D {
+	keys := bson.D{}
+	for _, field := range i.Keys {
+		if strings.HasPrefix(field, "-") {
+			keys = append(keys, bson.E{strings.TrimPrefix(field, "-"), -1})
+			continue
+		}
+		keys = append(keys, bson.E{field, 1})
+	}
+	return keys
+}
+
+func (i *Index) name() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	return defaultIndexName(i.keys())
+}
+
+// validateIndexes every index needs keys of plain fields and a unique name
+func validateIndexes(indexes []*Index) error {
+	names := map[string]bool{}
+	for _, idx := range indexes {
+		if idx == nil || len(idx.Keys) == 0 {
+			return ErrInvalidIndex
+		}
+		for _, field := range idx.Keys {
+			field = strings.TrimPrefix(field, "-")
+			if field == "" || strings.HasPrefix(field, "$") {
+				return ErrInvalidIndex
+			}
+		}
+		name := idx.name()
+		if names[name] || name == "_id_" {
+			return ErrInvalidIndex
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// existingIndex index as listed by the server
+type existingIndex struct {
+	Name   string `bson:"name"`
+	Key    bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+}
+
+// ReconcileIndexes compares declared indexes with the existing ones and
+// creates missing ones unless checkOnly is set. Nothing is done when no
+// index is declared, so collections managed elsewhere are left alone.
+// Shared are indexes declared by other datasets of the same collection,
+// they are reconciled by their datasets and not reported as extra.
+func (c *mClient) ReconcileIndexes(ctx context.Context, checkOnly bool, shared []*Index) (*IndexReport, error) {
+	report := &IndexReport{}
+	if len(c.indexes) == 0 {
+		return report, nil
+	}
+
+	cursor, err := c.collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var listed []*existingIndex
+	if err := cursor.All(ctx, &listed); err != nil {
+		return nil, err
+	}
+	existing := map[string]*existingIndex{}
+	for _, idx := range listed {
+		existing[idx.Name] = idx
+	}
+
+	var models []mongo.IndexModel
+	declared := map[string]bool{}
+	for _, idx := range shared {
+		declared[idx.name()] = true
+	}
+	for _, idx := range c.indexes {
+		name := idx.name()
+		declared[name] = true
+		current, ok := existing[name]
+		if !ok {
+			report.Missing = append(report.Missing, name)
+			models = append(models, mongo.IndexModel{
+				Keys:    idx.keys(),
+				Options: options.Index().SetName(name).SetUnique(idx.Unique),
+			})
+			continue
+		}
+		// key values are listed as int32 or double, compare them as text
+		if defaultIndexName(current.Key) != defaultIndexName(idx.keys()) || current.Unique != idx.Unique {
+			report.Changed = append(report.Changed, name)
+		}
+	}
+	for _, idx := range listed {
+		if idx.Name != "_id_" && !declared[idx.Name] {
+			report.Extra = append(report.Extra, idx.Name)
+		}
+	}
+
+	if checkOnly || len(models) == 0 {
+		return report, nil
+	}
+	created, err := c.collection.Indexes().CreateMany(ctx, models)
+	if err != nil {
+		return nil, err
+	}
+	report.Created = created
+	return report, nil
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_validateIndexes(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []*Index
+		wantErr error
+	}{
+		{name: "none", indexes: nil},
+		{name: "valid", indexes: []*Index{{Keys: []string{"created_at"}}, {Keys: []string{"key", "-created_at"}, Unique: true}}},
+		{name: "no keys", indexes: []*Index{{Name: "empty"}}, wantErr: ErrInvalidIndex},
+		{name: "empty field", indexes: []*Index{{Keys: []string{"-"}}}, wantErr: ErrInvalidIndex},
+		{name: "operator field", indexes: []*Index{{Keys: []string{"$key"}}}, wantErr: ErrInvalidIndex},
+		{name: "duplicate name", indexes: []*Index{{Keys: []string{"key"}}, {Name: "key_1", Keys: []string{"-key"}}}, wantErr: ErrInvalidIndex},
+		{name: "id index", indexes: []*Index{{Name: "_id_", Keys: []string{"key"}}}, wantErr: ErrInvalidIndex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateIndexes(tt.indexes); err != tt.wantErr {
+				t.Errorf("validateIndexes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIndex_name(t *testing.T) {
+	if got := (&Index{Keys: []string{"key", "-created_at"}}).name(); got != "key_1_created_at_-1" {
+		t.Errorf("Index.name() = %v, want key_1_created_at_-1", got)
+	}
+	if got := (&Index{Name: "by_key", Keys: []string{"key"}}).name(); got != "by_key" {
+		t.Errorf("Index.name() = %v, want by_key", got)
+	}
+}
+
+func Test_mClient_ReconcileIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	listed := func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.records", mtest.FirstBatch,
+			bson.D{{"v", 2}, {"key", bson.D{{"_id", 1}}}, {"name", "_id_"}},
+			bson.D{{"v", 2}, {"key", bson.D{{"created_at", int32(1)}}}, {"name", "created_at_1"}},
+			bson.D{{"v", 2}, {"key", bson.D{{"key", 1.0}}}, {"name", "by_key"}},
+			bson.D{{"v", 2}, {"key", bson.D{{"legacy", 1}}}, {"name", "legacy_1"}},
+		))
+	}
+
+	tests := []struct {
+		name      string
+		indexes   []*Index
+		shared    []*Index
+		checkOnly bool
+		mock      func(mt *mtest.T)
+		want      *IndexReport
+		wantDrift bool
+	}{
+		{
+			name: "nothing declared",
+			mock: func(mt *mtest.T) {},
+			want: &IndexReport{},
+		},
+		{
+			name:      "check only",
+			indexes:   []*Index{{Keys: []string{"created_at"}}, {Name: "by_key", Keys: []string{"-key"}}, {Keys: []string{"key", "created_at"}}},
+			checkOnly: true,
+			mock:      listed,
+			want: &IndexReport{
+				Missing: []string{"key_1_created_at_1"},
+				Changed: []string{"by_key"},
+				Extra:   []string{"legacy_1"},
+			},
+			wantDrift: true,
+		},
+		{
+			name:      "check only / shared",
+			indexes:   []*Index{{Keys: []string{"created_at"}}, {Name: "by_key", Keys: []string{"key"}}},
+			shared:    []*Index{{Keys: []string{"legacy"}}},
+			checkOnly: true,
+			mock:      listed,
+			want:      &IndexReport{},
+		},
+		{
+			name:    "create missing",
+			indexes: []*Index{{Keys: []string{"created_at"}}, {Name: "by_key", Keys: []string{"key"}}, {Name: "legacy_1", Keys: []string{"legacy"}}, {Keys: []string{"key", "created_at"}}},
+			mock: func(mt *mtest.T) {
+				listed(mt)
+				mt.AddMockResponses(mtest.CreateSuccessResponse())
+			},
+			want: &IndexReport{
+				Missing: []string{"key_1_created_at_1"},
+				Created: []string{"key_1_created_at_1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		mt.RunOpts(tt.name, mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+			tt.mock(mt)
+			c := &mClient{
+				client:     mt.Client,
+				database:   mt.DB,
+				collection: mt.Coll,
+				indexes:    tt.indexes,
+			}
+
+			got, err := c.ReconcileIndexes(context.Background(), tt.checkOnly, tt.shared)
+			if err != nil {
+				t.Errorf("mClient.ReconcileIndexes() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mClient.ReconcileIndexes() = %+v, want %+v", got, tt.want)
+			}
+			if got.Drift() != tt.wantDrift {
+				t.Errorf("IndexReport.Drift() = %v, want %v", got.Drift(), tt.wantDrift)
+			}
+		})
+	}
+}
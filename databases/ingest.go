@@ -0,0 +1,73 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongodbDocument record written into records collection
+type MongodbDocument struct {
+	Key       string `json:"key"`
+	Count     *int   `json:"count"`
+	CreatedAt *Time  `json:"createdAt"`
+}
+
+// MongodbInsertError error of a document, index is the document's position
+type MongodbInsertError struct {
+	Index int    `json:"index"`
+	Msg   string `json:"msg"`
+}
+
+type MongodbInsertResult struct {
+	Inserted int
+	Errors   []*MongodbInsertError
+}
+
+// Insert writes documents with a single InsertMany, ordered inserts stop at
+// the first failing document while unordered ones try every document
+func (c *mClient) Insert(ctx context.Context, docs []*MongodbDocument, ordered bool) (*MongodbInsertResult, error) {
+	if len(docs) == 0 {
+		return &MongodbInsertResult{}, nil
+	}
+	fields := c.fields.withDefaults()
+	values := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		values = append(values, bson.D{
+			{fields.Key, doc.Key},
+			{fields.Count, *doc.Count},
+			{fields.CreatedAt, primitive.NewDateTimeFromTime(doc.CreatedAt.Time())},
+		})
+	}
+
+	res, err := c.collection.InsertMany(ctx, values, options.InsertMany().SetOrdered(ordered))
+	if err == nil {
+		return &MongodbInsertResult{Inserted: len(res.InsertedIDs)}, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0 || res == nil {
+		return nil, err
+	}
+
+	// ids of failed documents, and documents after the failed one for
+	// ordered inserts, are removed from result by the driver
+	result := &MongodbInsertResult{Inserted: len(res.InsertedIDs)}
+	for _, we := range bulkErr.WriteErrors {
+		result.Errors = append(result.Errors, &MongodbInsertError{Index: we.Index, Msg: we.Message})
+	}
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Index < result.Errors[j].Index
+	})
+	return result, nil
+}
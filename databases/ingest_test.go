@@ -0,0 +1,111 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_mClient_Insert(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	doc := func(key string) *MongodbDocument {
+		count := 1
+		createdAt := Time(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+		return &MongodbDocument{Key: key, Count: &count, CreatedAt: &createdAt}
+	}
+	docs := []*MongodbDocument{doc("a"), doc("b"), doc("c")}
+
+	tests := []struct {
+		name    string
+		docs    []*MongodbDocument
+		ordered bool
+		mock    func(mt *mtest.T)
+		want    *MongodbInsertResult
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			docs:    nil,
+			mock:    func(mt *mtest.T) {},
+			want:    &MongodbInsertResult{},
+			wantErr: false,
+		},
+		{
+			name:    "all inserted",
+			docs:    docs,
+			ordered: true,
+			mock: func(mt *mtest.T) {
+				mt.AddMockResponses(mtest.CreateSuccessResponse())
+			},
+			want:    &MongodbInsertResult{Inserted: 3},
+			wantErr: false,
+		},
+		{
+			name:    "ordered / stops at first error",
+			docs:    docs,
+			ordered: true,
+			mock: func(mt *mtest.T) {
+				mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}))
+			},
+			want: &MongodbInsertResult{Inserted: 1, Errors: []*MongodbInsertError{
+				{Index: 1, Msg: "duplicate key"},
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "unordered / continues after error",
+			docs:    docs,
+			ordered: false,
+			mock: func(mt *mtest.T) {
+				mt.AddMockResponses(mtest.CreateWriteErrorsResponse(
+					mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"},
+					mtest.WriteError{Index: 2, Code: 11000, Message: "duplicate key"},
+				))
+			},
+			want: &MongodbInsertResult{Inserted: 1, Errors: []*MongodbInsertError{
+				{Index: 0, Msg: "duplicate key"},
+				{Index: 2, Msg: "duplicate key"},
+			}},
+			wantErr: false,
+		},
+		{
+			name:    "command error",
+			docs:    docs,
+			ordered: true,
+			mock: func(mt *mtest.T) {
+				mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Message: "unauthorized"}))
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		mt.RunOpts(tt.name, mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+			tt.mock(mt)
+			c := &mClient{
+				client:     mt.Client,
+				database:   mt.DB,
+				collection: mt.Coll,
+			}
+
+			got, err := c.Insert(context.Background(), tt.docs, tt.ordered)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mClient.Insert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mClient.Insert() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,30 +4,128 @@
 
 package databases
 
-import "sync"
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 var inmemory *sync.Map
 
+// inmemorySweepEvery interval of dropping expired keys of inmemory store
+const inmemorySweepEvery = time.Minute
+
+var (
+	sweepMu sync.Mutex
+	sweptAt time.Time
+)
+
+// inmemoryValue value of a key in inmemory store, zero expiresAt means key
+// never expires
+type inmemoryValue struct {
+	value     string
+	version   uint64
+	expiresAt time.Time
+}
+
+func (v *inmemoryValue) expired(at time.Time) bool {
+	return !v.expiresAt.IsZero() && !at.Before(v.expiresAt)
+}
+
+// load returns value of key, expired keys are missing until they are swept
+func load(key string) (*inmemoryValue, bool) {
+	v, ok := inmemory.Load(key)
+	if !ok {
+		return nil, false
+	}
+	value := v.(*inmemoryValue)
+	if value.expired(now()) {
+		return nil, false
+	}
+	return value, true
+}
+
+// store writes value of key with a new version and returns the version,
+// callers hold stripe lock of key
+func store(key, value string, expiresAt time.Time) uint64 {
+	version := atomic.AddUint64(&inmemoryVersions, 1)
+	inmemory.Store(key, &inmemoryValue{value: value, version: version, expiresAt: expiresAt})
+	return version
+}
+
+// sweep drops expired keys at most once in inmemorySweepEvery, it is called
+// after writes without holding any stripe lock
+func sweep() {
+	current := now()
+	sweepMu.Lock()
+	if current.Sub(sweptAt) < inmemorySweepEvery {
+		sweepMu.Unlock()
+		return
+	}
+	sweptAt = current
+	sweepMu.Unlock()
+
+	inmemory.Range(func(k, v interface{}) bool {
+		if !v.(*inmemoryValue).expired(current) {
+			return true
+		}
+		// key may be written again since it is ranged
+		lock := stripe(k.(string))
+		lock.Lock()
+		if v, ok := inmemory.Load(k); ok && v.(*inmemoryValue).expired(current) {
+			inmemory.Delete(k)
+		}
+		lock.Unlock()
+		return true
+	})
+}
+
+// stripes serialize writes of keys hashed to the same stripe, so read
+// modify write operations like Incr are not overwritten by plain writes
+var stripes [64]sync.Mutex
+
+func stripe(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &stripes[h.Sum32()%uint32(len(stripes))]
+}
+
 type InmemoryCommand struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
+	// Version of the value, set by Get and writes
+	Version uint64 `json:"-"`
 }
 
 type Inmemory interface {
 	Get(*InmemoryCommand) (*InmemoryCommand, error)
 	Set(*InmemoryCommand) error
+	// SetIf writes only when condition holds, ErrPreconditionFailed otherwise
+	SetIf(*InmemoryCommand, *Condition) error
+	// Delete reports whether key existed before it is deleted
+	Delete(*InmemoryCommand) (bool, error)
+	Exists(*InmemoryCommand) (bool, error)
+	Keys(*KeysCommand) (*KeysResult, error)
+	Incr(*IncrCommand) (*IncrResult, error)
+	GetMany([]string) ([]*BatchResult, error)
+	SetMany([]*InmemoryCommand) ([]*BatchResult, error)
 }
 
 type sS struct{}
 
 func (s *sS) Get(cmd *InmemoryCommand) (*InmemoryCommand, error) {
-	val, ok := inmemory.Load(cmd.Key)
+	if inmemory == nil {
+		return nil, ErrInmemoryInitializeFirst
+	}
+	val, ok := load(cmd.Key)
 	if !ok {
 		return nil, ErrInmemoryKeyNotFound
 	}
 	return &InmemoryCommand{
-		Key:   cmd.Key,
-		Value: val.(string),
+		Key:     cmd.Key,
+		Value:   val.value,
+		Version: val.version,
 	}, nil
 }
 
@@ -35,11 +133,43 @@ func (s *sS) Set(cmd *InmemoryCommand) error {
 	if inmemory == nil {
 		return ErrInmemoryInitializeFirst
 	}
-	inmemory.Store(cmd.Key, cmd.Value)
+	lock := stripe(cmd.Key)
+	lock.Lock()
+	cmd.Version = store(cmd.Key, cmd.Value, time.Time{})
+	lock.Unlock()
+	sweep()
 
 	return nil
 }
 
+func (s *sS) Delete(cmd *InmemoryCommand) (bool, error) {
+	if inmemory == nil {
+		return false, ErrInmemoryInitializeFirst
+	}
+	lock := stripe(cmd.Key)
+	lock.Lock()
+	defer lock.Unlock()
+	_, ok := load(cmd.Key)
+	inmemory.Delete(cmd.Key)
+
+	return ok, nil
+}
+
+func (s *sS) Exists(cmd *InmemoryCommand) (bool, error) {
+	if inmemory == nil {
+		return false, ErrInmemoryInitializeFirst
+	}
+	_, ok := load(cmd.Key)
+
+	return ok, nil
+}
+
+// NewInmemory returns the inmemory store, its methods fail with
+// ErrInmemoryInitializeFirst until InitializeInmemory is called
+func NewInmemory() Inmemory {
+	return &sS{}
+}
+
 func InitializeInmemory(cfg *Database) (Inmemory, error) {
 	if cfg == nil {
 		return nil, ErrConfigParameterMissing
@@ -8,10 +8,12 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 func tearUp() {
 	inmemory = &sync.Map{}
+	inmemoryVersions = 0
 }
 
 func tearDown() {
@@ -40,11 +42,12 @@ func Test_sS_Get(t *testing.T) {
 			name: "get / success",
 			args: args{cmd: &InmemoryCommand{Key: "test"}},
 			want: &InmemoryCommand{
-				Key:   "test",
-				Value: "testinmemory",
+				Key:     "test",
+				Value:   "testinmemory",
+				Version: 1,
 			},
 			setup: func() {
-				inmemory.LoadOrStore("test", "testinmemory")
+				store("test", "testinmemory", time.Time{})
 			},
 			wantErr: false,
 		},
@@ -107,6 +110,37 @@ func Test_sS_Set(t *testing.T) {
 	}
 }
 
+func Test_sS_DeleteExists(t *testing.T) {
+	s := &sS{}
+	tearDown()
+	if _, err := s.Delete(&InmemoryCommand{Key: "test"}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.Delete() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+	if _, err := s.Exists(&InmemoryCommand{Key: "test"}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.Exists() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+
+	tearUp()
+	defer tearDown()
+	store("test", "testinmemory", time.Time{})
+	steps := []struct {
+		name string
+		op   func(*InmemoryCommand) (bool, error)
+		want bool
+	}{
+		{name: "exists", op: s.Exists, want: true},
+		{name: "delete", op: s.Delete, want: true},
+		{name: "exists after delete", op: s.Exists, want: false},
+		{name: "delete again", op: s.Delete, want: false},
+	}
+	for _, step := range steps {
+		got, err := step.op(&InmemoryCommand{Key: "test"})
+		if err != nil || got != step.want {
+			t.Errorf("%s = %v, %v, want %v", step.name, got, err, step.want)
+		}
+	}
+}
+
 func TestInitializeInmemory(t *testing.T) {
 	type args struct {
 		cfg *Database
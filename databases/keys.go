@@ -0,0 +1,277 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultKeysCount = 10
+	MaxKeysCount     = 1000
+)
+
+// KeysCommand page of keys matching glob pattern after cursor, empty match
+// means all keys and empty cursor the first page
+type KeysCommand struct {
+	Match  string
+	Cursor string
+	Count  int
+}
+
+// KeysResult keys of a page, cursor of the next page is empty on the last page
+type KeysResult struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor,omitempty"`
+}
+
+func (cmd *KeysCommand) count() int {
+	if cmd.Count <= 0 {
+		return DefaultKeysCount
+	}
+	return cmd.Count
+}
+
+// Keys scans keys with SCAN, so redis is never blocked like KEYS does.
+// Count is a hint for redis, pages may have more or less keys than count
+// and keys may be returned more than once if they are changed while scanning.
+// Keys storing versions of other keys are not returned.
+func (r *RedisConnection) Keys(cmd *KeysCommand) (*KeysResult, error) {
+	if r == nil {
+		return nil, ErrRedisInitializeFirst
+	}
+	var cursor uint64
+	if cmd.Cursor != "" {
+		c, err := strconv.ParseUint(cmd.Cursor, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidKeysCursor
+		}
+		cursor = c
+	}
+	match := cmd.Match
+	if match == "" {
+		match = "*"
+	}
+
+	keys, next, err := r.client.Scan(context.Background(), cursor, match, int64(cmd.count())).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := &KeysResult{Keys: []string{}}
+	for _, key := range keys {
+		if !isVersionKey(key) {
+			result.Keys = append(result.Keys, key)
+		}
+	}
+	if next != 0 {
+		result.Cursor = strconv.FormatUint(next, 10)
+	}
+	return result, nil
+}
+
+// keysSnapshotTTL snapshots of inmemory keys not paged for this long are dropped
+const keysSnapshotTTL = 5 * time.Minute
+
+// maxKeysSnapshots upper bound of kept snapshots, least recently paged one
+// is dropped for a new one
+const maxKeysSnapshots = 64
+
+// keysSnapshot sorted keys matching pattern of a listing when it started
+type keysSnapshot struct {
+	keys   []string
+	usedAt time.Time
+}
+
+var (
+	snapshotsMu sync.Mutex
+	snapshots   = map[string]*keysSnapshot{}
+)
+
+// Keys returns keys in ascending order from a snapshot of the store, taken
+// when the first page is requested. Cursor is the snapshot and position of
+// the next page in it, so pages neither skip nor repeat keys when the store
+// changes while paging, keys written after the first page are not listed
+// and deleted ones are. Match of the first page is used for all pages.
+// Snapshots not paged for keysSnapshotTTL expire, their cursor is invalid.
+func (s *sS) Keys(cmd *KeysCommand) (*KeysResult, error) {
+	if inmemory == nil {
+		return nil, ErrInmemoryInitializeFirst
+	}
+	var id string
+	var offset int
+	var snapshot *keysSnapshot
+	if cmd.Cursor != "" {
+		var err error
+		if id, offset, snapshot, err = loadSnapshot(cmd.Cursor); err != nil {
+			return nil, err
+		}
+	} else {
+		id, snapshot = takeSnapshot(cmd.Match)
+	}
+
+	count := cmd.count()
+	end := offset + count
+	// last page keeps the snapshot, so it can be requested again
+	if end >= len(snapshot.keys) {
+		return &KeysResult{Keys: snapshot.keys[offset:]}, nil
+	}
+	return &KeysResult{Keys: snapshot.keys[offset:end], Cursor: id + "." + strconv.Itoa(end)}, nil
+}
+
+// takeSnapshot keeps sorted keys matching pattern and returns its id
+func takeSnapshot(match string) (string, *keysSnapshot) {
+	current := now()
+	keys := []string{}
+	inmemory.Range(func(k, v interface{}) bool {
+		key := k.(string)
+		if v.(*inmemoryValue).expired(current) || (match != "" && !matchGlob(match, key)) {
+			return true
+		}
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+
+	b := make([]byte, 12)
+	rand.Read(b)
+	id := base64.RawURLEncoding.EncodeToString(b)
+	snapshot := &keysSnapshot{keys: keys, usedAt: current}
+
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	var oldest string
+	for key, kept := range snapshots {
+		if current.Sub(kept.usedAt) >= keysSnapshotTTL {
+			delete(snapshots, key)
+			continue
+		}
+		if oldest == "" || kept.usedAt.Before(snapshots[oldest].usedAt) {
+			oldest = key
+		}
+	}
+	if len(snapshots) >= maxKeysSnapshots {
+		delete(snapshots, oldest)
+	}
+	snapshots[id] = snapshot
+	return id, snapshot
+}
+
+// loadSnapshot returns snapshot and position of cursor
+func loadSnapshot(cursor string) (string, int, *keysSnapshot, error) {
+	dot := strings.LastIndexByte(cursor, '.')
+	if dot < 0 {
+		return "", 0, nil, ErrInvalidKeysCursor
+	}
+	id := cursor[:dot]
+	offset, err := strconv.Atoi(cursor[dot+1:])
+	if err != nil || offset < 0 {
+		return "", 0, nil, ErrInvalidKeysCursor
+	}
+
+	current := now()
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	snapshot, ok := snapshots[id]
+	if !ok || current.Sub(snapshot.usedAt) >= keysSnapshotTTL || offset > len(snapshot.keys) {
+		return "", 0, nil, ErrInvalidKeysCursor
+	}
+	snapshot.usedAt = current
+	return id, offset, snapshot, nil
+}
+
+// matchGlob matches s against redis glob pattern, * matches any sequence,
+// ? any character, [abc], [^abc] and [a-z] character classes and \ escapes.
+// Only the last * is backtracked to, so matching takes at most
+// len(pattern)*len(s) steps regardless of the number of stars.
+func matchGlob(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		if pi < len(p) {
+			if p[pi] == '*' {
+				star, mark = pi, si
+				pi++
+				continue
+			}
+			if next, ok := matchOne(p, pi, str[si]); ok {
+				pi, si = next, si+1
+				continue
+			}
+		}
+		// let the last * take one more character
+		if star < 0 {
+			return false
+		}
+		mark++
+		pi, si = star+1, mark
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// matchOne matches c against pattern element at i which is not a *,
+// returns index of the next element
+func matchOne(p []rune, i int, c rune) (int, bool) {
+	switch p[i] {
+	case '?':
+		return i + 1, true
+	case '[':
+		matched, rest, ok := matchClass(p[i+1:], c)
+		if !ok {
+			// unterminated class matches [ literally like redis
+			return i + 1, c == '['
+		}
+		return len(p) - len(rest), matched
+	case '\\':
+		if i+1 < len(p) {
+			i++
+		}
+	}
+	return i + 1, p[i] == c
+}
+
+// matchClass matches c against character class which starts after [,
+// returns pattern after closing ] and false ok when class is not terminated
+func matchClass(p []rune, c rune) (bool, []rune, bool) {
+	negate := len(p) > 0 && p[0] == '^'
+	if negate {
+		p = p[1:]
+	}
+	matched := false
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == ']':
+			return matched != negate, p[i+1:], true
+		case p[i] == '\\' && i+1 < len(p):
+			i++
+			if p[i] == c {
+				matched = true
+			}
+		case i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']':
+			lo, hi := p[i], p[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			i += 2
+		case p[i] == c:
+			matched = true
+		}
+	}
+	return false, nil, false
+}
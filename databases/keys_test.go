@@ -0,0 +1,185 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+)
+
+func Test_matchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "session:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"h[llo", "h[llo", true},
+		{"*:*:*", "a:b:c", true},
+		{"*:*:*", "a:b", false},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbc", false},
+		{"a*b*c", "abbbc", true},
+		{"[a-c]*[!]", "b!", true},
+		{`*\*`, "ab*", true},
+		{"**", "", true},
+		{"?*", "", false},
+		{"h[llo*", "h[llo world", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.s, func(t *testing.T) {
+			if got := matchGlob(tt.pattern, tt.s); got != tt.want {
+				t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchGlob_backtracking(t *testing.T) {
+	// recursive matching tries every split of every star, this never ends
+	pattern := strings.Repeat("*a", 30) + "*b"
+	s := strings.Repeat("a", 1000)
+	done := make(chan bool)
+	go func() { done <- matchGlob(pattern, s) }()
+	select {
+	case got := <-done:
+		if got {
+			t.Errorf("matchGlob() = true, want false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("matchGlob() backtracks exponentially")
+	}
+}
+
+func Test_sS_Keys(t *testing.T) {
+	s := &sS{}
+	tearDown()
+	if _, err := s.Keys(&KeysCommand{}); err != ErrInmemoryInitializeFirst {
+		t.Errorf("sS.Keys() error = %v, want %v", err, ErrInmemoryInitializeFirst)
+	}
+
+	tearUp()
+	defer tearDown()
+	for _, key := range []string{"user:3", "user:1", "session:1", "user:2"} {
+		store(key, "v", time.Time{})
+	}
+
+	first, err := s.Keys(&KeysCommand{Match: "user:*", Count: 2})
+	if err != nil {
+		t.Errorf("sS.Keys() error = %v", err)
+		return
+	}
+	if !reflect.DeepEqual(first.Keys, []string{"user:1", "user:2"}) || first.Cursor == "" {
+		t.Errorf("sS.Keys() = %+v, want first page", first)
+	}
+
+	// pages are of the snapshot taken by the first page
+	inmemory.Delete("user:2")
+	store("user:0", "v", time.Time{})
+	store("user:4", "v", time.Time{})
+	second, err := s.Keys(&KeysCommand{Match: "user:*", Count: 2, Cursor: first.Cursor})
+	if err != nil {
+		t.Errorf("sS.Keys() error = %v", err)
+		return
+	}
+	if !reflect.DeepEqual(second, &KeysResult{Keys: []string{"user:3"}}) {
+		t.Errorf("sS.Keys() = %+v, want last page", second)
+	}
+	if again, _ := s.Keys(&KeysCommand{Cursor: first.Cursor}); !reflect.DeepEqual(again, second) {
+		t.Errorf("sS.Keys() = %+v, want last page again", again)
+	}
+
+	all, _ := s.Keys(&KeysCommand{})
+	if !reflect.DeepEqual(all.Keys, []string{"session:1", "user:0", "user:1", "user:3", "user:4"}) {
+		t.Errorf("sS.Keys() = %+v, want all keys", all)
+	}
+
+	for _, cursor := range []string{"!!", "unknown.2", first.Cursor[:len(first.Cursor)-1] + "9"} {
+		if _, err := s.Keys(&KeysCommand{Cursor: cursor}); err != ErrInvalidKeysCursor {
+			t.Errorf("sS.Keys(%s) error = %v, want %v", cursor, err, ErrInvalidKeysCursor)
+		}
+	}
+}
+
+func Test_sS_Keys_snapshotExpiry(t *testing.T) {
+	s := &sS{}
+	tearUp()
+	defer tearDown()
+	current := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+	for _, key := range []string{"a", "b", "c"} {
+		store(key, "v", time.Time{})
+	}
+
+	first, _ := s.Keys(&KeysCommand{Count: 1})
+	current = current.Add(keysSnapshotTTL - time.Second)
+	second, err := s.Keys(&KeysCommand{Count: 1, Cursor: first.Cursor})
+	if err != nil || !reflect.DeepEqual(second.Keys, []string{"b"}) {
+		t.Errorf("sS.Keys() = %+v, %v, want second page", second, err)
+	}
+	// paging keeps the snapshot
+	current = current.Add(keysSnapshotTTL - time.Second)
+	if _, err := s.Keys(&KeysCommand{Count: 1, Cursor: second.Cursor}); err != nil {
+		t.Errorf("sS.Keys() error = %v", err)
+	}
+	current = current.Add(keysSnapshotTTL)
+	if _, err := s.Keys(&KeysCommand{Count: 1, Cursor: second.Cursor}); err != ErrInvalidKeysCursor {
+		t.Errorf("sS.Keys() error = %v, want %v", err, ErrInvalidKeysCursor)
+	}
+
+	// oldest snapshot is dropped for a new one
+	snapshots = map[string]*keysSnapshot{}
+	oldest, _ := s.Keys(&KeysCommand{Count: 1})
+	for i := 0; i < maxKeysSnapshots; i++ {
+		current = current.Add(time.Second)
+		s.Keys(&KeysCommand{Count: 1})
+	}
+	if len(snapshots) != maxKeysSnapshots {
+		t.Errorf("snapshots = %d, want %d", len(snapshots), maxKeysSnapshots)
+	}
+	if _, err := s.Keys(&KeysCommand{Count: 1, Cursor: oldest.Cursor}); err != ErrInvalidKeysCursor {
+		t.Errorf("sS.Keys() error = %v, want %v", err, ErrInvalidKeysCursor)
+	}
+}
+
+func TestRedisConnection_Keys(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	r := &RedisConnection{client: db}
+
+	mock.ExpectScan(0, "*", 10).SetVal([]string{"a", versionCounter, "b", versionKey("a")}, 17)
+	mock.ExpectScan(17, "user:*", 100).SetVal(nil, 0)
+
+	got, err := r.Keys(&KeysCommand{})
+	if err != nil || !reflect.DeepEqual(got, &KeysResult{Keys: []string{"a", "b"}, Cursor: "17"}) {
+		t.Errorf("RedisConnection.Keys() = %+v, %v", got, err)
+	}
+	got, err = r.Keys(&KeysCommand{Match: "user:*", Cursor: "17", Count: 100})
+	if err != nil || !reflect.DeepEqual(got, &KeysResult{Keys: []string{}}) {
+		t.Errorf("RedisConnection.Keys() = %+v, %v", got, err)
+	}
+	if _, err := r.Keys(&KeysCommand{Cursor: "abc"}); err != ErrInvalidKeysCursor {
+		t.Errorf("RedisConnection.Keys() error = %v, want %v", err, ErrInvalidKeysCursor)
+	}
+}
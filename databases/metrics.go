@@ -0,0 +1,165 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	MetricSum       = "sum"
+	MetricAvg       = "avg"
+	MetricMin       = "min"
+	MetricMax       = "max"
+	MetricStdDev    = "stdDev"
+	MetricDocCount  = "docCount"
+	MetricFirstSeen = "firstSeen"
+	MetricLastSeen  = "lastSeen"
+)
+
+// Metrics all metrics which can be requested, in the order of export columns
+var Metrics = []string{
+	MetricSum, MetricAvg, MetricMin, MetricMax,
+	MetricStdDev, MetricDocCount, MetricFirstSeen, MetricLastSeen,
+}
+
+// MongodbMetrics aggregate metrics of a key over count field,
+// only requested metrics are set
+type MongodbMetrics struct {
+	Sum       *int     `bson:"sum,omitempty" json:"sum,omitempty"`
+	Avg       *float64 `bson:"avg,omitempty" json:"avg,omitempty"`
+	Min       *int     `bson:"min,omitempty" json:"min,omitempty"`
+	Max       *int     `bson:"max,omitempty" json:"max,omitempty"`
+	StdDev    *float64 `bson:"stdDev,omitempty" json:"stdDev,omitempty"`
+	DocCount  *int     `bson:"docCount,omitempty" json:"docCount,omitempty"`
+	FirstSeen *string  `bson:"firstSeen,omitempty" json:"firstSeen,omitempty"`
+	LastSeen  *string  `bson:"lastSeen,omitempty" json:"lastSeen,omitempty"`
+}
+
+// Format returns metric value as string, empty string if metric is not set
+func (m *MongodbMetrics) Format(name string) string {
+	if m == nil {
+		return ""
+	}
+	formatInt := func(i *int) string {
+		if i == nil {
+			return ""
+		}
+		return strconv.Itoa(*i)
+	}
+	formatFloat := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+	formatString := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	switch name {
+	case MetricSum:
+		return formatInt(m.Sum)
+	case MetricAvg:
+		return formatFloat(m.Avg)
+	case MetricMin:
+		return formatInt(m.Min)
+	case MetricMax:
+		return formatInt(m.Max)
+	case MetricStdDev:
+		return formatFloat(m.StdDev)
+	case MetricDocCount:
+		return formatInt(m.DocCount)
+	case MetricFirstSeen:
+		return formatString(m.FirstSeen)
+	case MetricLastSeen:
+		return formatString(m.LastSeen)
+	}
+	return ""
+}
+
+// partial accumulator fields every metric can be derived from, they are
+// combinable so bucketed groups can be merged into key's metrics
+var metricPartials = []string{
+	"partialSum", "partialSumSq", "partialDocCount",
+	"partialMin", "partialMax", "partialFirst", "partialLast",
+}
+
+// partialAccumulators accumulators over raw documents
+func partialAccumulators(fields Fields) bson.D {
+	count, createdAt := "$"+fields.Count, "$"+fields.CreatedAt
+	return bson.D{
+		{"partialSum", bson.D{{"$sum", count}}},
+		{"partialSumSq", bson.D{{"$sum", bson.D{{"$multiply", bson.A{count, count}}}}}},
+		{"partialDocCount", bson.D{{"$sum", 1}}},
+		{"partialMin", bson.D{{"$min", count}}},
+		{"partialMax", bson.D{{"$max", count}}},
+		{"partialFirst", bson.D{{"$min", createdAt}}},
+		{"partialLast", bson.D{{"$max", createdAt}}},
+	}
+}
+
+// combineAccumulators accumulators over already grouped partials
+func combineAccumulators() bson.D {
+	return bson.D{
+		{"partialSum", bson.D{{"$sum", "$partialSum"}}},
+		{"partialSumSq", bson.D{{"$sum", "$partialSumSq"}}},
+		{"partialDocCount", bson.D{{"$sum", "$partialDocCount"}}},
+		{"partialMin", bson.D{{"$min", "$partialMin"}}},
+		{"partialMax", bson.D{{"$max", "$partialMax"}}},
+		{"partialFirst", bson.D{{"$min", "$partialFirst"}}},
+		{"partialLast", bson.D{{"$max", "$partialLast"}}},
+	}
+}
+
+// metricExpression derives requested metric from partials
+func metricExpression(name string) interface{} {
+	mean := bson.D{{"$divide", bson.A{"$partialSum", "$partialDocCount"}}}
+	switch name {
+	case MetricSum:
+		return "$partialSum"
+	case MetricAvg:
+		return mean
+	case MetricMin:
+		return "$partialMin"
+	case MetricMax:
+		return "$partialMax"
+	case MetricStdDev:
+		// population standard deviation, E[x^2] - E[x]^2 can be slightly
+		// negative because of floating point error
+		variance := bson.D{{"$subtract", bson.A{
+			bson.D{{"$divide", bson.A{"$partialSumSq", "$partialDocCount"}}},
+			bson.D{{"$pow", bson.A{mean, 2}}},
+		}}}
+		return bson.D{{"$sqrt", bson.D{{"$max", bson.A{0, variance}}}}}
+	case MetricDocCount:
+		return "$partialDocCount"
+	case MetricFirstSeen:
+		return bson.D{{"$dateToString", bson.D{{"date", "$partialFirst"}}}}
+	case MetricLastSeen:
+		return bson.D{{"$dateToString", bson.D{{"date", "$partialLast"}}}}
+	}
+	return nil
+}
+
+// metricsStages builds metrics document from partials and drops partials
+func metricsStages(f *MongodbFilter) []bson.D {
+	if len(f.Metrics) == 0 {
+		return nil
+	}
+	metrics := bson.D{}
+	for _, name := range f.Metrics {
+		metrics = append(metrics, bson.E{name, metricExpression(name)})
+	}
+	return []bson.D{
+		{{"$set", bson.D{{"metrics", metrics}}}},
+		{{"$unset", metricPartials}},
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMongodbMetrics_Format(t *testing.T) {
+	i := 3
+	f := 1.5
+	s := "2023-01-02T00:00:00.000Z"
+	m := &MongodbMetrics{Sum: &i, Avg: &f, FirstSeen: &s}
+	tests := []struct {
+		name    string
+		metrics *MongodbMetrics
+		metric  string
+		want    string
+	}{
+		{name: "int", metrics: m, metric: MetricSum, want: "3"},
+		{name: "float", metrics: m, metric: MetricAvg, want: "1.5"},
+		{name: "string", metrics: m, metric: MetricFirstSeen, want: s},
+		{name: "not set", metrics: m, metric: MetricMax, want: ""},
+		{name: "unknown", metrics: m, metric: "median", want: ""},
+		{name: "nil", metrics: nil, metric: MetricSum, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metrics.Format(tt.metric); got != tt.want {
+				t.Errorf("MongodbMetrics.Format() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_metricsStages(t *testing.T) {
+	tests := []struct {
+		name string
+		f    *MongodbFilter
+		want []bson.D
+	}{
+		{
+			name: "no metrics",
+			f:    &MongodbFilter{},
+			want: nil,
+		},
+		{
+			name: "requested metrics only",
+			f:    &MongodbFilter{Metrics: []string{MetricMax, MetricDocCount}},
+			want: []bson.D{
+				{{"$set", bson.D{{"metrics", bson.D{
+					{"max", "$partialMax"},
+					{"docCount", "$partialDocCount"},
+				}}}}},
+				{{"$unset", metricPartials}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metricsStages(tt.f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("metricsStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
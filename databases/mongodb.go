@@ -7,7 +7,10 @@ package databases
 
 import (
 	"context"
+	"encoding/json"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,15 +20,19 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+const dateLayout = "2006-01-02"
+
 type Time time.Time
 
+// UnmarshalJSON accepts RFC3339 timestamps, dates and relative time
+// expressions, dates are midnight of UTC
 func (c *Time) UnmarshalJSON(b []byte) error {
 	value := strings.Trim(string(b), `"`) //get rid of "
 	if value == "" || value == "null" {
 		return nil
 	}
 
-	t, err := time.Parse("2006-01-02", value) //parse time
+	t, err := parseTime(value, time.UTC, false)
 	if err != nil {
 		return err
 	}
@@ -37,25 +44,192 @@ func (c *Time) Time() time.Time {
 	return time.Time(*c)
 }
 
+// MarshalJSON dates marshaled as date only, other times as RFC3339
 func (c Time) MarshalJSON() ([]byte, error) {
-	return []byte(`"` + time.Time(c).Format("2006-01-02") + `"`), nil
+	t := time.Time(c)
+	if t.Equal(t.Truncate(24*time.Hour)) && t.Location() == time.UTC {
+		return []byte(`"` + t.Format(dateLayout) + `"`), nil
+	}
+	return []byte(`"` + t.Format(time.RFC3339Nano) + `"`), nil
+}
+
+// parseTime parses RFC3339 timestamp, date or relative time expression in
+// given location, date resolved to the last millisecond of the day when
+// endOfDay is set so date ranges include their last day
+func parseTime(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if isRelativeTime(value) {
+		return parseRelativeTime(value, loc, endOfDay)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+	return t, nil
 }
 
 type MongodbRecord struct {
-	Key        string `bson:"_id" json:"key"`
-	CreatedAt  string `bson:"createdAt" json:"createdAt"`
-	TotalCount int    `bson:"totalCount" json:"totalCount"`
+	Key        string           `bson:"_id" json:"key"`
+	CreatedAt  string           `bson:"createdAt" json:"createdAt"`
+	TotalCount int              `bson:"totalCount" json:"totalCount"`
+	Series     []*MongodbBucket `bson:"series,omitempty" json:"series,omitempty"`
+	Metrics    *MongodbMetrics  `bson:"metrics,omitempty" json:"metrics,omitempty"`
+}
+
+// MongodbBucket total count of a key in a time bucket, bucket is the start
+// of bucket in UTC, or in timezone of the filter with its offset when given
+type MongodbBucket struct {
+	Bucket string `bson:"bucket" json:"bucket"`
+	Count  int    `bson:"count" json:"count"`
+}
+
+const (
+	SortByKey        = "key"
+	SortByTotalCount = "totalCount"
+	SortByCreatedAt  = "createdAt"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// sortFields maps sortBy values to fields of grouped documents
+var sortFields = map[string]string{
+	SortByKey:        "_id",
+	SortByTotalCount: "totalCount",
+	SortByCreatedAt:  "createdAt",
 }
 
 type MongodbFilter struct {
-	StartDate *Time `json:"startDate"`
-	EndDate   *Time `json:"endDate"`
-	MinCount  *int  `json:"minCount"`
-	MaxCount  *int  `json:"maxCount"`
+	StartDate *Time    `json:"startDate"`
+	EndDate   *Time    `json:"endDate"`
+	MinCount  *int     `json:"minCount"`
+	MaxCount  *int     `json:"maxCount"`
+	Limit     *int     `json:"limit"`
+	Cursor    string   `json:"cursor"`
+	SortBy    string   `json:"sortBy"`
+	Order     string   `json:"order"`
+	Bucket    string   `json:"bucket"`
+	Metrics   []string `json:"metrics"`
+	// total count filters applied to groups, not to records
+	MinTotalCount *int `json:"minTotalCount"`
+	MaxTotalCount *int `json:"maxTotalCount"`
+	// key filters, all given ones must match
+	Keys       []string `json:"keys"`
+	KeyPrefix  string   `json:"keyPrefix"`
+	KeyPattern string   `json:"keyPattern"`
+	// IANA time zone name which dates and buckets are resolved in, UTC by default
+	Timezone string `json:"timezone"`
+	// records, count of matching keys or summary of them, records by default
+	Mode string `json:"mode"`
+	// relative time expressions of startDate and endDate before they are
+	// resolved, results of relative filters are cached by expression
+	startExpr string
+	endExpr   string
+}
+
+// UnmarshalJSON dates and relative times of startDate and endDate are resolved
+// in filter's timezone, endDate date covers the whole day
+func (f *MongodbFilter) UnmarshalJSON(b []byte) error {
+	type filter MongodbFilter
+	aux := struct {
+		*filter
+		StartDate *string `json:"startDate"`
+		EndDate   *string `json:"endDate"`
+	}{filter: (*filter)(f)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	loc, err := f.Location()
+	if err != nil {
+		return err
+	}
+	if f.StartDate, err = resolveTime(aux.StartDate, loc, false); err != nil {
+		return err
+	}
+	if f.EndDate, err = resolveTime(aux.EndDate, loc, true); err != nil {
+		return err
+	}
+	if aux.StartDate != nil && isRelativeTime(*aux.StartDate) {
+		f.startExpr = *aux.StartDate
+	}
+	if aux.EndDate != nil && isRelativeTime(*aux.EndDate) {
+		f.endExpr = *aux.EndDate
+	}
+	return nil
+}
+
+func resolveTime(value *string, loc *time.Location, endOfDay bool) (*Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := parseTime(*value, loc, endOfDay)
+	if err != nil {
+		return nil, err
+	}
+	c := Time(t)
+	return &c, nil
+}
+
+// Location returns location of filter's timezone
+func (f *MongodbFilter) Location() (*time.Location, error) {
+	if f.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// SortField returns requested sort field, records sorted by key by default
+func (f *MongodbFilter) SortField() string {
+	if f.SortBy == "" {
+		return SortByKey
+	}
+	return f.SortBy
+}
+
+// SortOrder returns requested sort order, ascending by default
+func (f *MongodbFilter) SortOrder() string {
+	if f.Order == "" {
+		return OrderAsc
+	}
+	return f.Order
+}
+
+// IsTotals reports whether filter asks for totals of keys instead of records
+func (f *MongodbFilter) IsTotals() bool {
+	return f.Mode == ModeCount || f.Mode == ModeSummary
+}
+
+// MongodbResult records of a page, or count or summary in totals modes
+type MongodbResult struct {
+	Records    []*MongodbRecord
+	NextCursor string
+	Count      *int
+	Summary    *MongodbSummary
+	// HIT or MISS when result is served through cache
+	Cache string `json:"-"`
 }
 
 type MongoClient interface {
-	Fetch(*MongodbFilter) ([]*MongodbRecord, error)
+	Fetch(context.Context, *MongodbFilter) (*MongodbResult, error)
+	Explain(context.Context, *MongodbFilter) (*MongodbExplain, error)
+	Stream(context.Context, *MongodbFilter, func(*MongodbRecord) error) error
+	Insert(context.Context, []*MongodbDocument, bool) (*MongodbInsertResult, error)
+	Watch(context.Context, *MongodbFilter, string) (MongodbEventStream, error)
 }
 
 // wrap mongo client to write more easy tests
@@ -63,21 +237,31 @@ type mClient struct {
 	client     *mongo.Client
 	database   *mongo.Database
 	collection *mongo.Collection
+	fields     *Fields
+	// server side time limit of aggregations, no limit when zero
+	maxTime time.Duration
+	// fetches taking longer are logged, nothing logged when zero
+	slowQuery time.Duration
+	// declared indexes of collection
+	indexes []*Index
+	// daily rollup of collection, nil when not configured
+	rollup *rollup
 }
 
-var client *mongo.Client
-var database *mongo.Database
+// clients connections by connection string, datasets on the same server
+// share a connection
+var clients = map[string]*mongo.Client{}
+var clientsMu sync.Mutex
 
-func InitializeMongodb(cfg *Database) (*mClient, error) {
-	if cfg == nil {
-		return nil, ErrConfigParameterMissing
-	}
+func connectMongodb(conn string) (*mongo.Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
 
 	// already initialized
-	if client != nil {
-		return &mClient{client: client, database: database}, nil
+	if client, ok := clients[conn]; ok {
+		return client, nil
 	}
-	cOptions := options.Client().ApplyURI(cfg.Conn)
+	cOptions := options.Client().ApplyURI(conn)
 	client, err := mongo.NewClient(cOptions)
 	if err != nil {
 		return nil, err
@@ -89,11 +273,154 @@ func InitializeMongodb(cfg *Database) (*mClient, error) {
 	if err := client.Ping(context.Background(), readpref.Primary()); err != nil {
 		return nil, err
 	}
+	clients[conn] = client
+	return client, nil
+}
+
+func InitializeMongodb(cfg *Database) (*mClient, error) {
+	if cfg == nil {
+		return nil, ErrConfigParameterMissing
+	}
+	if err := cfg.validateDataset(); err != nil {
+		return nil, err
+	}
+
+	fields := cfg.Fields.withDefaults()
+	if err := fields.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MaxTimeMS < 0 {
+		return nil, ErrInvalidMaxTime
+	}
+	if cfg.SlowQueryMS < 0 {
+		return nil, ErrInvalidSlowQuery
+	}
+	if err := validateIndexes(cfg.Indexes); err != nil {
+		return nil, err
+	}
+	if cfg.Cache != nil {
+		if err := cfg.Cache.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Rollup != nil {
+		if err := cfg.Rollup.validate(); err != nil {
+			return nil, err
+		}
+	}
+	collection := cfg.collectionName()
+
+	client, err := connectMongodb(cfg.Conn)
+	if err != nil {
+		return nil, err
+	}
 	database := client.Database(cfg.Name)
-	return &mClient{client: client, database: database, collection: database.Collection("records")}, nil
+	c := &mClient{
+		client:     client,
+		database:   database,
+		collection: database.Collection(collection),
+		fields:     &fields,
+		maxTime:    time.Duration(cfg.MaxTimeMS) * time.Millisecond,
+		slowQuery:  time.Duration(cfg.SlowQueryMS) * time.Millisecond,
+		indexes:    cfg.Indexes,
+	}
+	if cfg.Rollup != nil {
+		c.rollup = sharedRollup(cfg.RollupKey(), database, collection, cfg.Rollup)
+	}
+	return c, nil
 }
 
-func (c *mClient) Fetch(f *MongodbFilter) ([]*MongodbRecord, error) {
+// Fetch runs aggregation until ctx is done or server side time limit exceeded
+func (c *mClient) Fetch(ctx context.Context, f *MongodbFilter) (*MongodbResult, error) {
+	pipeline, err := c.pipeline(f)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var result *MongodbResult
+	if f.IsTotals() {
+		result, err = c.fetchTotals(ctx, f, pipeline)
+	} else {
+		result, err = c.fetchRecords(ctx, f, pipeline)
+	}
+	c.logSlowQuery(pipeline, time.Since(start), result, err)
+	return result, err
+}
+
+func (c *mClient) fetchRecords(ctx context.Context, f *MongodbFilter, pipeline mongo.Pipeline) (*MongodbResult, error) {
+	cursor, err := c.collection.Aggregate(ctx, pipeline, c.aggregateOptions())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(context.Background())
+
+	var records []*MongodbRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	result := &MongodbResult{Records: records}
+	// one more record than limit fetched, so we know there is a next page
+	if f.Limit != nil && len(records) > *f.Limit {
+		result.Records = records[:*f.Limit]
+		last := result.Records[len(result.Records)-1]
+		result.NextCursor = nextCursor(f, last)
+	}
+	return result, nil
+}
+
+// Stream iterates aggregation cursor and calls fn for every record instead of
+// loading all of them into memory, iteration stops at the first error of fn
+func (c *mClient) Stream(ctx context.Context, f *MongodbFilter, fn func(*MongodbRecord) error) error {
+	pipeline, err := c.pipeline(f)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := c.collection.Aggregate(ctx, pipeline, c.aggregateOptions())
+	if err != nil {
+		return err
+	}
+
+	defer cursor.Close(context.Background())
+
+	for n := 0; cursor.Next(ctx); n++ {
+		// pipeline fetches one more record than limit for next cursor
+		if f.Limit != nil && n == *f.Limit {
+			break
+		}
+		record := &MongodbRecord{}
+		if err := cursor.Decode(record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// aggregateOptions maxTimeMS is sent with aggregation so server kills the
+// query even if the client never cancels it
+func (c *mClient) aggregateOptions() *options.AggregateOptions {
+	opts := options.Aggregate()
+	if c.maxTime > 0 {
+		opts.SetMaxTime(c.maxTime)
+	}
+	return opts
+}
+
+// IsTimeout reports whether err is caused by a deadline, either of the
+// request's context or server side time limit
+func IsTimeout(err error) bool {
+	return mongo.IsTimeout(err)
+}
+
+func (c *mClient) pipeline(f *MongodbFilter) (mongo.Pipeline, error) {
+	fields := c.fields.withDefaults()
 	createdAtFilter := bson.M{}
 	minMaxFilter := bson.M{}
 	if f.EndDate != nil || f.StartDate != nil {
@@ -105,7 +432,7 @@ func (c *mClient) Fetch(f *MongodbFilter) ([]*MongodbRecord, error) {
 		if f.EndDate != nil {
 			m["$lte"] = primitive.NewDateTimeFromTime(f.EndDate.Time())
 		}
-		createdAtFilter = bson.M{"created_at": m}
+		createdAtFilter = bson.M{fields.CreatedAt: m}
 	}
 	if f.MinCount != nil || f.MaxCount != nil {
 		m := make(map[string]interface{})
@@ -115,33 +442,185 @@ func (c *mClient) Fetch(f *MongodbFilter) ([]*MongodbRecord, error) {
 		if f.MaxCount != nil {
 			m["$lte"] = *f.MaxCount
 		}
-		minMaxFilter = bson.M{"count": m}
+		minMaxFilter = bson.M{fields.Count: m}
 	}
 
-	groupStage := bson.D{
-		{"$group", bson.D{
-			{"_id", "$key"},
-			{"totalCount", bson.D{{"$sum", "$count"}}},
-		},
-		},
+	// createdAt of group is the first record's creation time
+	createdAtStage := bson.D{
+		{"$set", bson.D{
+			{"createdAt", bson.D{{"$dateToString", bson.D{{"date", "$createdAt"}}}}},
+		}},
 	}
-	matchStage := bson.D{
-		{"$match", bson.D{
-			{"$and", []bson.M{createdAtFilter, minMaxFilter}}}},
+	var pipeline mongo.Pipeline
+	if from, to, ok := c.rollupRange(f); ok {
+		// whole days answered by rollup, only edges aggregated from records
+		pipeline = c.rollupStages(f, fields, from, to)
+		pipeline = append(pipeline, havingStages(f)...)
+		if f.IsTotals() {
+			return append(pipeline, totalsStage(f)), nil
+		}
+	} else {
+		matchStage := bson.D{
+			{"$match", bson.D{
+				{"$and", append([]bson.M{createdAtFilter, minMaxFilter}, keyFilters(f, fields.Key)...)}}},
+		}
+		pipeline = mongo.Pipeline{matchStage}
+		if f.IsTotals() {
+			return append(pipeline, totalStages(f, fields)...), nil
+		}
+		pipeline = append(pipeline, groupStages(f, fields)...)
+		pipeline = append(pipeline, havingStages(f)...)
 	}
-	cursor, err := c.collection.Aggregate(context.TODO(), mongo.Pipeline{
-		matchStage,
-		groupStage})
+	pipeline = append(pipeline, createdAtStage)
+	pipeline = append(pipeline, metricsStages(f)...)
 
+	pageStages, err := sortStages(f)
 	if err != nil {
 		return nil, err
 	}
+	return append(pipeline, pageStages...), nil
+}
 
-	defer cursor.Close(context.TODO())
+// keyFilters filters of key field, all of them must match
+func keyFilters(f *MongodbFilter, field string) []bson.M {
+	filters := []bson.M{}
+	if len(f.Keys) > 0 {
+		filters = append(filters, bson.M{field: bson.M{"$in": f.Keys}})
+	}
+	if f.KeyPrefix != "" {
+		filters = append(filters, bson.M{field: bson.M{"$regex": "^" + regexp.QuoteMeta(f.KeyPrefix)}})
+	}
+	if f.KeyPattern != "" {
+		filters = append(filters, bson.M{field: bson.M{"$regex": f.KeyPattern}})
+	}
+	return filters
+}
 
-	var records []*MongodbRecord
-	if err := cursor.All(context.TODO(), &records); err != nil {
-		return nil, err
+// havingStages total count filters applied to grouped keys
+func havingStages(f *MongodbFilter) []bson.D {
+	if f.MinTotalCount == nil && f.MaxTotalCount == nil {
+		return nil
+	}
+	m := bson.D{}
+	if f.MinTotalCount != nil {
+		m = append(m, bson.E{"$gte", *f.MinTotalCount})
+	}
+	if f.MaxTotalCount != nil {
+		m = append(m, bson.E{"$lte", *f.MaxTotalCount})
+	}
+	return []bson.D{{{"$match", bson.D{{"totalCount", m}}}}}
+}
+
+// groupStages sums counts per key, when bucket requested counts are summed
+// per key and truncated creation time first then pushed into key's series
+func groupStages(f *MongodbFilter, fields Fields) []bson.D {
+	if f.Bucket == "" {
+		group := bson.D{
+			{"_id", "$" + fields.Key},
+			{"totalCount", bson.D{{"$sum", "$" + fields.Count}}},
+			{"createdAt", bson.D{{"$min", "$" + fields.CreatedAt}}},
+		}
+		if len(f.Metrics) > 0 {
+			group = append(group, partialAccumulators(fields)...)
+		}
+		return []bson.D{{{"$group", group}}}
+	}
+
+	trunc := bson.D{{"date", "$" + fields.CreatedAt}, {"unit", f.Bucket}}
+	if f.Timezone != "" {
+		trunc = append(trunc, bson.E{"timezone", f.Timezone})
+	}
+	if f.Bucket == BucketWeek {
+		trunc = append(trunc, bson.E{"startOfWeek", "monday"})
+	}
+	bucketGroup := bson.D{
+		{"_id", bson.D{
+			{"key", "$" + fields.Key},
+			{"bucket", bson.D{{"$dateTrunc", trunc}}},
+		}},
+		{"count", bson.D{{"$sum", "$" + fields.Count}}},
+		{"createdAt", bson.D{{"$min", "$" + fields.CreatedAt}}},
+	}
+	keyGroup := bson.D{
+		{"_id", "$_id.key"},
+		{"totalCount", bson.D{{"$sum", "$count"}}},
+		{"createdAt", bson.D{{"$min", "$createdAt"}}},
+		{"series", bson.D{{"$push", bson.D{
+			{"bucket", bucketLabel(f)},
+			{"count", "$count"},
+		}}}},
+	}
+	if len(f.Metrics) > 0 {
+		bucketGroup = append(bucketGroup, partialAccumulators(fields)...)
+		keyGroup = append(keyGroup, combineAccumulators()...)
+	}
+	return []bson.D{
+		{{"$group", bucketGroup}},
+		// series pushed in this order
+		{{"$sort", bson.D{{"_id.bucket", 1}}}},
+		{{"$group", keyGroup}},
+	}
+}
+
+// bucketLabel formats start of bucket, buckets of a timezone are labelled
+// with their local time so a day starts at midnight in its label
+func bucketLabel(f *MongodbFilter) bson.D {
+	if f.Timezone == "" {
+		return bson.D{{"$dateToString", bson.D{{"date", "$_id.bucket"}}}}
+	}
+	return bson.D{{"$dateToString", bson.D{
+		{"date", "$_id.bucket"},
+		{"format", "%Y-%m-%dT%H:%M:%S.%L%z"},
+		{"timezone", f.Timezone},
+	}}}
+}
+
+// sortStages sorts groups by requested field with _id as tiebreaker, by key
+// when nothing is requested so records are always in the same order, and
+// applies keyset pagination on top of it, records after cursor returned
+// so new inserts don't shift pages like $skip does
+func sortStages(f *MongodbFilter) ([]bson.D, error) {
+	field := sortFields[f.SortField()]
+	direction, op := 1, "$gt"
+	if f.SortOrder() == OrderDesc {
+		direction, op = -1, "$lt"
+	}
+
+	var stages []bson.D
+	if f.Cursor != "" {
+		cursor, err := DecodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		keyset := bson.D{{"_id", bson.D{{op, cursor.ID}}}}
+		if field != "_id" {
+			keyset = bson.D{{"$or", bson.A{
+				bson.D{{field, bson.D{{op, cursor.Value}}}},
+				bson.D{{field, cursor.Value}, {"_id", bson.D{{op, cursor.ID}}}},
+			}}}
+		}
+		stages = append(stages, bson.D{{"$match", keyset}})
+	}
+
+	sort := bson.D{{"_id", direction}}
+	if field != "_id" {
+		sort = bson.D{{field, direction}, {"_id", direction}}
+	}
+	stages = append(stages, bson.D{{"$sort", sort}})
+	if f.Limit != nil {
+		stages = append(stages, bson.D{{"$limit", *f.Limit + 1}})
+	}
+	return stages, nil
+}
+
+// nextCursor position of the given record for the filter's sort
+func nextCursor(f *MongodbFilter, r *MongodbRecord) string {
+	c := &Cursor{ID: r.Key, SortBy: f.SortField(), Order: f.SortOrder()}
+	switch c.SortBy {
+	case SortByTotalCount:
+		c.Value = r.TotalCount
+	case SortByCreatedAt:
+		c.Value = r.CreatedAt
 	}
-	return records, nil
+	return EncodeCursor(c)
 }
@@ -6,6 +6,8 @@
 package databases
 
 import (
+	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
@@ -26,10 +28,11 @@ func Test_mClient_Fetch(t *testing.T) {
 		mock func(mt *mtest.T)
 	}
 	type test struct {
-		base    base
-		name    string
-		args    args
-		wantErr bool
+		base       base
+		name       string
+		args       args
+		wantErr    bool
+		wantCursor string
 	}
 
 	baseTest := base{
@@ -62,6 +65,66 @@ func Test_mClient_Fetch(t *testing.T) {
 		},
 	}
 
+	limitTest := base{
+		want: baseTest.want[:1],
+		mock: baseTest.mock,
+	}
+
+	bucketTest := base{
+		want: []*MongodbRecord{
+			&MongodbRecord{
+				Key:        "testing",
+				TotalCount: 3,
+				CreatedAt:  "2023-01-02",
+				Series: []*MongodbBucket{
+					{Bucket: "2023-01-02T00:00:00.000Z", Count: 1},
+					{Bucket: "2023-01-03T00:00:00.000Z", Count: 2},
+				},
+			},
+		},
+		mock: func(mt *mtest.T) {
+			first := mtest.CreateCursorResponse(0, "test.recods", mtest.FirstBatch, bson.D{
+				{"_id", "testing"},
+				{"totalCount", 3},
+				{"createdAt", "2023-01-02"},
+				{"series", bson.A{
+					bson.D{{"bucket", "2023-01-02T00:00:00.000Z"}, {"count", 1}},
+					bson.D{{"bucket", "2023-01-03T00:00:00.000Z"}, {"count", 2}},
+				}},
+			})
+			mt.AddMockResponses(first)
+		},
+	}
+
+	metricsTest := base{
+		want: []*MongodbRecord{
+			&MongodbRecord{
+				Key:        "testing",
+				TotalCount: 3,
+				CreatedAt:  "2023-01-02",
+				Metrics: &MongodbMetrics{
+					Avg: func() *float64 {
+						f := 1.5
+						return &f
+					}(),
+					DocCount: func() *int {
+						i := 2
+						return &i
+					}(),
+				},
+			},
+		},
+		mock: func(mt *mtest.T) {
+			first := mtest.CreateCursorResponse(0, "test.recods", mtest.FirstBatch, bson.D{
+				{"_id", "testing"},
+				{"totalCount", 3},
+				{"createdAt", "2023-01-02"},
+				{"metrics", bson.D{{"avg", 1.5}, {"docCount", 2}}},
+			})
+			mt.AddMockResponses(first)
+		},
+	}
+
 	tests := []test{
 		{
 			name: "full records",
@@ -118,6 +181,120 @@ func Test_mClient_Fetch(t *testing.T) {
 			},
 			base: baseTest,
 		},
+		{
+			name: "full records / with minTotalCount and maxTotalCount",
+			args: args{
+				f: &MongodbFilter{
+					MinTotalCount: func() *int {
+						i := 2
+						return &i
+					}(),
+					MaxTotalCount: func() *int {
+						i := 3
+						return &i
+					}(),
+				},
+			},
+			base: baseTest,
+		},
+		{
+			name: "full records / with keys, keyPrefix and keyPattern",
+			args: args{
+				f: &MongodbFilter{
+					Keys:       []string{"testing", "testing2"},
+					KeyPrefix:  "test.",
+					KeyPattern: "^test",
+				},
+			},
+			base: baseTest,
+		},
+		{
+			name: "full records / with limit",
+			args: args{
+				f: &MongodbFilter{
+					Limit: func() *int {
+						i := 1
+						return &i
+					}(),
+				},
+			},
+			base:       limitTest,
+			wantCursor: EncodeCursor(&Cursor{ID: "testing", SortBy: SortByKey, Order: OrderAsc}),
+		},
+		{
+			name: "full records / sort by totalCount desc with limit",
+			args: args{
+				f: &MongodbFilter{
+					Limit: func() *int {
+						i := 1
+						return &i
+					}(),
+					SortBy: SortByTotalCount,
+					Order:  OrderDesc,
+				},
+			},
+			base:       limitTest,
+			wantCursor: EncodeCursor(&Cursor{ID: "testing", SortBy: SortByTotalCount, Order: OrderDesc, Value: 3}),
+		},
+		{
+			name: "full records / sort by createdAt with cursor",
+			args: args{
+				f: &MongodbFilter{
+					Cursor: EncodeCursor(&Cursor{ID: "a", SortBy: SortByCreatedAt, Order: OrderAsc, Value: "2023-01-01"}),
+					SortBy: SortByCreatedAt,
+				},
+			},
+			base: baseTest,
+		},
+		{
+			name: "full records / with cursor",
+			args: args{
+				f: &MongodbFilter{
+					Cursor: EncodeCursor(&Cursor{ID: "a"}),
+				},
+			},
+			base: baseTest,
+		},
+		{
+			name: "full records / with day bucket",
+			args: args{
+				f: &MongodbFilter{Bucket: BucketDay},
+			},
+			base: bucketTest,
+		},
+		{
+			name: "full records / with week bucket",
+			args: args{
+				f: &MongodbFilter{Bucket: BucketWeek},
+			},
+			base: bucketTest,
+		},
+		{
+			name: "full records / with metrics",
+			args: args{
+				f: &MongodbFilter{Metrics: []string{MetricAvg, MetricDocCount}},
+			},
+			base: metricsTest,
+		},
+		{
+			name: "full records / with metrics and bucket",
+			args: args{
+				f: &MongodbFilter{Metrics: []string{MetricAvg, MetricDocCount}, Bucket: BucketMonth},
+			},
+			base: metricsTest,
+		},
+		{
+			name: "full records / with invalid cursor",
+			args: args{
+				f: &MongodbFilter{
+					Cursor: "!!",
+				},
+			},
+			base: base{
+				mock: func(mt *mtest.T) {},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		mt.RunOpts(tt.name, mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
@@ -128,14 +305,164 @@ func Test_mClient_Fetch(t *testing.T) {
 				collection: mt.Coll,
 			}
 
-			got, err := c.Fetch(tt.args.f)
+			got, err := c.Fetch(context.Background(), tt.args.f)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("mClient.Fetch() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
-			if !reflect.DeepEqual(got, tt.base.want) {
-				t.Errorf("mClient.Fetch() = %v, want %v", got, tt.base.want)
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got.Records, tt.base.want) {
+				t.Errorf("mClient.Fetch() = %v, want %v", got.Records, tt.base.want)
+			}
+			if got.NextCursor != tt.wantCursor {
+				t.Errorf("mClient.Fetch() cursor = %v, want %v", got.NextCursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func Test_mClient_pipeline(t *testing.T) {
+	c := &mClient{fields: &Fields{Key: "event", Count: "value", CreatedAt: "ts"}}
+	minCount := 1
+	got, err := c.pipeline(&MongodbFilter{MinCount: &minCount, Keys: []string{"a"}})
+	if err != nil {
+		t.Errorf("mClient.pipeline() error = %v", err)
+		return
+	}
+
+	wantMatch := bson.D{{"$match", bson.D{{"$and", []bson.M{
+		{},
+		{"value": map[string]interface{}{"$gte": 1}},
+		{"event": bson.M{"$in": []string{"a"}}},
+	}}}}}
+	if !reflect.DeepEqual(got[0], wantMatch) {
+		t.Errorf("mClient.pipeline() match = %v, want %v", got[0], wantMatch)
+	}
+	wantGroup := bson.D{{"$group", bson.D{
+		{"_id", "$event"},
+		{"totalCount", bson.D{{"$sum", "$value"}}},
+		{"createdAt", bson.D{{"$min", "$ts"}}},
+	}}}
+	if !reflect.DeepEqual(got[1], wantGroup) {
+		t.Errorf("mClient.pipeline() group = %v, want %v", got[1], wantGroup)
+	}
+	// records are sorted by key even when no sort is requested
+	wantSort := bson.D{{"$sort", bson.D{{"_id", 1}}}}
+	if last := got[len(got)-1]; !reflect.DeepEqual(last, wantSort) {
+		t.Errorf("mClient.pipeline() sort = %v, want %v", last, wantSort)
+	}
+}
+
+func Test_groupStages_bucketLabel(t *testing.T) {
+	fields := defaultFields
+	tests := []struct {
+		name string
+		f    *MongodbFilter
+		want bson.D
+	}{
+		{
+			name: "utc",
+			f:    &MongodbFilter{Bucket: BucketDay},
+			want: bson.D{{"$dateToString", bson.D{{"date", "$_id.bucket"}}}},
+		},
+		{
+			name: "timezone",
+			f:    &MongodbFilter{Bucket: BucketDay, Timezone: "Europe/Istanbul"},
+			want: bson.D{{"$dateToString", bson.D{
+				{"date", "$_id.bucket"},
+				{"format", "%Y-%m-%dT%H:%M:%S.%L%z"},
+				{"timezone", "Europe/Istanbul"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages := groupStages(tt.f, fields)
+			keyGroup := stages[len(stages)-1][0].Value.(bson.D)
+			var got interface{}
+			for _, e := range keyGroup {
+				if e.Key == "series" {
+					got = e.Value.(bson.D)[0].Value.(bson.D)[0].Value
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupStages() bucket = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mClient_Stream(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mock := func(mt *mtest.T) {
+		first := mtest.CreateCursorResponse(1, "test.recods", mtest.FirstBatch, bson.D{
+			{"_id", "testing"},
+			{"totalCount", 3},
+		})
+		getMore := mtest.CreateCursorResponse(1, "test.recods", mtest.NextBatch, bson.D{
+			{"_id", "testing2"},
+			{"totalCount", 2},
+		})
+		killCursors := mtest.CreateCursorResponse(0, "test.recods", mtest.NextBatch)
+		mt.AddMockResponses(first, getMore, killCursors)
+	}
+
+	tests := []struct {
+		name    string
+		f       *MongodbFilter
+		fnErr   error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "all records",
+			f:    &MongodbFilter{},
+			want: []string{"testing", "testing2"},
+		},
+		{
+			name: "with limit",
+			f: &MongodbFilter{
+				Limit: func() *int {
+					i := 1
+					return &i
+				}(),
+			},
+			want: []string{"testing"},
+		},
+		{
+			name:    "callback error",
+			f:       &MongodbFilter{},
+			fnErr:   ErrInvalidCursor,
+			want:    []string{"testing"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		mt.RunOpts(tt.name, mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+			mock(mt)
+			c := &mClient{
+				client:     mt.Client,
+				database:   mt.DB,
+				collection: mt.Coll,
+			}
+
+			var got []string
+			err := c.Stream(context.Background(), tt.f, func(r *MongodbRecord) error {
+				got = append(got, r.Key)
+				return tt.fnErr
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mClient.Stream() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mClient.Stream() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -155,6 +482,16 @@ func TestTime_UnmarshalJSON(t *testing.T) {
 			args:    args{b: []byte("2023-02-12")},
 			wantErr: false,
 		},
+		{
+			name:    "rfc3339",
+			args:    args{b: []byte(`"2023-02-12T10:20:30+03:00"`)},
+			wantErr: false,
+		},
+		{
+			name:    "rfc3339 / wrong",
+			args:    args{b: []byte(`"2023-02-12T25:20:30Z"`)},
+			wantErr: true,
+		},
 		{
 			name:    "wrong date",
 			args:    args{b: []byte("2023-02-30")},
@@ -209,6 +546,7 @@ func TestTime_Time(t *testing.T) {
 func TestTime_MarshalJSON(t *testing.T) {
 	tests := []struct {
 		name    string
+		c       Time
 		want    []byte
 		wantErr bool
 	}{
@@ -217,10 +555,22 @@ func TestTime_MarshalJSON(t *testing.T) {
 			want:    []byte("\"0001-01-01\""),
 			wantErr: false,
 		},
+		{
+			name:    "date",
+			c:       Time(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)),
+			want:    []byte("\"2023-01-02\""),
+			wantErr: false,
+		},
+		{
+			name:    "time",
+			c:       Time(time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)),
+			want:    []byte("\"2023-01-02T10:00:00Z\""),
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := Time{}
+			c := tt.c
 			got, err := c.MarshalJSON()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Time.MarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
@@ -232,3 +582,108 @@ func TestTime_MarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestMongodbFilter_UnmarshalJSON(t *testing.T) {
+	now = func() time.Time { return time.Date(2023, 3, 15, 22, 30, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	istanbul, _ := time.LoadLocation("Europe/Istanbul")
+	tests := []struct {
+		name      string
+		b         string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "dates / utc",
+			b:         `{"startDate":"2023-01-02","endDate":"2023-01-03"}`,
+			wantStart: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2023, 1, 3, 23, 59, 59, 999000000, time.UTC),
+		},
+		{
+			name:      "dates / timezone after dates",
+			b:         `{"startDate":"2023-01-02","endDate":"2023-01-03","timezone":"Europe/Istanbul"}`,
+			wantStart: time.Date(2023, 1, 2, 0, 0, 0, 0, istanbul),
+			wantEnd:   time.Date(2023, 1, 3, 23, 59, 59, 999000000, istanbul),
+		},
+		{
+			name:      "rfc3339 / end is not extended",
+			b:         `{"startDate":"2023-01-02T10:00:00Z","endDate":"2023-01-03T10:00:00+03:00","timezone":"Europe/Istanbul"}`,
+			wantStart: time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2023, 1, 3, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "relative / timezone",
+			b:         `{"startDate":"today-1d/d","endDate":"today-1d/d","timezone":"Europe/Istanbul"}`,
+			wantStart: time.Date(2023, 3, 15, 0, 0, 0, 0, istanbul),
+			wantEnd:   time.Date(2023, 3, 15, 23, 59, 59, 999000000, istanbul),
+		},
+		{
+			name:    "relative / invalid",
+			b:       `{"startDate":"now-1q"}`,
+			wantErr: true,
+		},
+		{
+			name: "empty",
+			b:    `{"startDate":null,"endDate":""}`,
+		},
+		{
+			name:    "invalid timezone",
+			b:       `{"startDate":"2023-01-02","timezone":"Mars/Olympus"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid date",
+			b:       `{"startDate":"2023-02-30"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &MongodbFilter{}
+			err := json.Unmarshal([]byte(tt.b), f)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MongodbFilter.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			var gotStart, gotEnd time.Time
+			if f.StartDate != nil {
+				gotStart = f.StartDate.Time()
+			}
+			if f.EndDate != nil {
+				gotEnd = f.EndDate.Time()
+			}
+			if !gotStart.Equal(tt.wantStart) || !gotEnd.Equal(tt.wantEnd) {
+				t.Errorf("MongodbFilter.UnmarshalJSON() = %v - %v, want %v - %v", gotStart, gotEnd, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func Test_mClient_aggregateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxTime time.Duration
+		want    *time.Duration
+	}{
+		{name: "no limit", maxTime: 0, want: nil},
+		{name: "limit", maxTime: 2 * time.Second, want: durationPtr(2 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mClient{maxTime: tt.maxTime}
+			got := c.aggregateOptions().MaxTime
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mClient.aggregateOptions() MaxTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
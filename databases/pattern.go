@@ -0,0 +1,63 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"regexp/syntax"
+)
+
+const (
+	maxKeyPatternLength = 256
+	maxKeyPatternNodes  = 64
+)
+
+// ValidateKeyPattern checks key pattern before it is sent to mongodb, patterns
+// must be parseable by go regexp (no backreferences or lookarounds), small and
+// must not contain nested repetitions like (a+)+ which backtrack exponentially
+func ValidateKeyPattern(pattern string) error {
+	if len(pattern) > maxKeyPatternLength {
+		return ErrKeyPatternTooComplex
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return ErrInvalidKeyPattern
+	}
+	if countNodes(re) > maxKeyPatternNodes || hasNestedRepeat(re, false) {
+		return ErrKeyPatternTooComplex
+	}
+	return nil
+}
+
+func countNodes(re *syntax.Regexp) int {
+	n := 1
+	for _, sub := range re.Sub {
+		n += countNodes(sub)
+	}
+	return n
+}
+
+func isRepeat(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		return true
+	case syntax.OpRepeat:
+		return re.Max == -1 || re.Max > 1
+	}
+	return false
+}
+
+func hasNestedRepeat(re *syntax.Regexp, inRepeat bool) bool {
+	repeat := isRepeat(re)
+	if repeat && inRepeat {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasNestedRepeat(sub, inRepeat || repeat) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateKeyPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr error
+	}{
+		{name: "simple", pattern: "^user-[0-9]+$", wantErr: nil},
+		{name: "alternation", pattern: "^(order|invoice)-.*", wantErr: nil},
+		{name: "bounded repeat inside repeat", pattern: "(ab?)+", wantErr: nil},
+		{name: "syntax error", pattern: "(abc", wantErr: ErrInvalidKeyPattern},
+		{name: "backreference", pattern: `(a)\1`, wantErr: ErrInvalidKeyPattern},
+		{name: "nested plus", pattern: "(a+)+$", wantErr: ErrKeyPatternTooComplex},
+		{name: "nested star in counted repeat", pattern: "(a*){2,}", wantErr: ErrKeyPatternTooComplex},
+		{name: "too long", pattern: strings.Repeat("a", maxKeyPatternLength+1), wantErr: ErrKeyPatternTooComplex},
+		{name: "too many nodes", pattern: strings.Repeat("(ab|cd)", 30), wantErr: ErrKeyPatternTooComplex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateKeyPattern(tt.pattern); err != tt.wantErr {
+				t.Errorf("ValidateKeyPattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
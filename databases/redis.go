@@ -7,17 +7,82 @@ package databases
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 var rdb *redis.Client
 
+// RedisCommand key value pair, key expires after TTL or at ExpiresAt when
+// one of them given, TTL of Get result is the remaining time to live
 type RedisCommand struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	TTL       *Duration  `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Version of the value, set by Get and writes
+	Version uint64 `json:"-"`
 }
 
+// Duration accepts seconds as number or duration string like 1h30m,
+// marshaled as whole seconds
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	value := strings.Trim(string(b), `"`)
+	if value == "" || value == "null" {
+		return nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	if len(b) == 0 || b[0] != '"' {
+		return ErrInvalidTTL
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return ErrInvalidTTL
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int64(math.Ceil(time.Duration(d).Seconds())))
+}
+
+// expiration validates TTL and ExpiresAt of command, zero TTL and
+// expiresAt means key never expires
+func (cmd *RedisCommand) expiration() (time.Duration, time.Time, error) {
+	if cmd.TTL != nil && cmd.ExpiresAt != nil {
+		return 0, time.Time{}, ErrRedisTTLConflict
+	}
+	if cmd.TTL != nil {
+		ttl := time.Duration(*cmd.TTL)
+		// redis expires keys in milliseconds at best
+		if ttl < time.Millisecond {
+			return 0, time.Time{}, ErrInvalidTTL
+		}
+		return ttl, time.Time{}, nil
+	}
+	if cmd.ExpiresAt != nil {
+		if !cmd.ExpiresAt.After(time.Now()) {
+			return 0, time.Time{}, ErrInvalidTTL
+		}
+		return 0, *cmd.ExpiresAt, nil
+	}
+	return 0, time.Time{}, nil
+}
+
+// RedisConnection connection of redis, methods of nil connection fail with
+// ErrRedisInitializeFirst so endpoints work when redis is not configured
 type RedisConnection struct {
 	client *redis.Client
 }
@@ -25,6 +90,15 @@ type RedisConnection struct {
 type Redis interface {
 	Get(*RedisCommand) (*RedisCommand, error)
 	Set(*RedisCommand) error
+	// SetIf writes only when condition holds, ErrPreconditionFailed otherwise
+	SetIf(*RedisCommand, *Condition) error
+	// Delete reports whether key existed before it is deleted
+	Delete(*RedisCommand) (bool, error)
+	Exists(*RedisCommand) (bool, error)
+	Keys(*KeysCommand) (*KeysResult, error)
+	Incr(*IncrCommand) (*IncrResult, error)
+	GetMany([]string) ([]*BatchResult, error)
+	SetMany([]*RedisCommand) ([]*BatchResult, error)
 }
 
 func InitializeRedis(cfg *Database) (*RedisConnection, error) {
@@ -51,22 +125,113 @@ func InitializeRedis(cfg *Database) (*RedisConnection, error) {
 	return &RedisConnection{client: rdb}, nil
 }
 
+// Set writes value with a new version taken from the version counter,
+// value and version are written in a transaction. Version of cmd is set to
+// the written version.
 func (r *RedisConnection) Set(cmd *RedisCommand) error {
-	if s := r.client.Set(context.Background(), cmd.Key, cmd.Value, 0); s.Err() != nil {
-		return s.Err()
+	if r == nil {
+		return ErrRedisInitializeFirst
+	}
+	if err := writable(cmd.Key); err != nil {
+		return err
+	}
+	ttl, expiresAt, err := cmd.expiration()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	version, err := r.client.Incr(ctx, versionCounter).Result()
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		setVersioned(ctx, p, cmd, version, ttl, expiresAt)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	cmd.Version = uint64(version)
 	return nil
 }
 
+// Get returns value with remaining time to live and version, read in a
+// transaction so the version is of the returned value, values written by
+// other clients have no version
 func (r *RedisConnection) Get(cmd *RedisCommand) (*RedisCommand, error) {
-	s := r.client.Get(context.Background(), cmd.Key)
+	if r == nil {
+		return nil, ErrRedisInitializeFirst
+	}
+	var s, version *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := r.client.TxPipelined(context.Background(), func(p redis.Pipeliner) error {
+		s = p.Get(context.Background(), cmd.Key)
+		ttl = p.TTL(context.Background(), cmd.Key)
+		version = p.Get(context.Background(), versionKey(cmd.Key))
+		return nil
+	})
+	if errors.Is(s.Err(), redis.Nil) {
+		return nil, ErrRedisKeyNotFound
+	}
 	if s.Err() != nil {
 		return nil, s.Err()
 	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
 
-	return &RedisCommand{
+	result := &RedisCommand{
 		Key:   cmd.Key,
 		Value: s.Val(),
-	}, nil
+	}
+	// negative ttl means key has no expiration
+	if ttl.Val() > 0 {
+		d := Duration(ttl.Val())
+		result.TTL = &d
+	}
+	if v, err := version.Uint64(); err == nil {
+		result.Version = v
+	}
+	return result, nil
+}
+
+// IsKeyNotFound reports whether err is returned for a missing key by Get of
+// redis or inmemory
+func IsKeyNotFound(err error) bool {
+	return err == ErrRedisKeyNotFound || err == ErrInmemoryKeyNotFound
+}
+
+// Delete deletes key together with its version
+func (r *RedisConnection) Delete(cmd *RedisCommand) (bool, error) {
+	if r == nil {
+		return false, ErrRedisInitializeFirst
+	}
+	if err := writable(cmd.Key); err != nil {
+		return false, err
+	}
+	var s *redis.IntCmd
+	_, err := r.client.TxPipelined(context.Background(), func(p redis.Pipeliner) error {
+		s = p.Del(context.Background(), cmd.Key)
+		p.Del(context.Background(), versionKey(cmd.Key))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return s.Val() > 0, nil
+}
+
+func (r *RedisConnection) Exists(cmd *RedisCommand) (bool, error) {
+	if r == nil {
+		return false, ErrRedisInitializeFirst
+	}
+	s := r.client.Exists(context.Background(), cmd.Key)
+	if s.Err() != nil {
+		return false, s.Err()
+	}
+
+	return s.Val() > 0, nil
 }
@@ -6,8 +6,11 @@
 package databases
 
 import (
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redismock/v9"
 	"github.com/redis/go-redis/v9"
@@ -46,7 +49,11 @@ func TestRedisConnection_Get(t *testing.T) {
 			name: "get / success",
 			fields: func() *fields {
 				db, mock := redismock.NewClientMock()
+				mock.ExpectTxPipeline()
 				mock.ExpectGet("testredis").SetVal("testvalue")
+				mock.ExpectTTL("testredis").SetVal(-1)
+				mock.ExpectGet(versionKey("testredis")).SetVal("4")
+				mock.ExpectTxPipelineExec()
 				return &fields{
 					client: db,
 				}
@@ -54,9 +61,34 @@ func TestRedisConnection_Get(t *testing.T) {
 			args: args{
 				cmd: &RedisCommand{Key: "testredis", Value: "testvalue"},
 			},
+			want: &RedisCommand{
+				Key:     "testredis",
+				Value:   "testvalue",
+				Version: 4,
+			},
+			wantErr: false,
+		},
+		{
+			name: "get / success / ttl",
+			fields: func() *fields {
+				db, mock := redismock.NewClientMock()
+				mock.ExpectTxPipeline()
+				mock.ExpectGet("testredis").SetVal("testvalue")
+				mock.ExpectTTL("testredis").SetVal(time.Minute)
+				// written by another client without version
+				mock.ExpectGet(versionKey("testredis")).RedisNil()
+				mock.ExpectTxPipelineExec()
+				return &fields{
+					client: db,
+				}
+			}(),
+			args: args{
+				cmd: &RedisCommand{Key: "testredis"},
+			},
 			want: &RedisCommand{
 				Key:   "testredis",
 				Value: "testvalue",
+				TTL:   durationOf(time.Minute),
 			},
 			wantErr: false,
 		},
@@ -79,6 +111,7 @@ func TestRedisConnection_Get(t *testing.T) {
 }
 
 func TestRedisConnection_Set(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
 	type fields struct {
 		client *redis.Client
 	}
@@ -111,7 +144,11 @@ func TestRedisConnection_Set(t *testing.T) {
 			name: "set / success",
 			fields: func() *fields {
 				db, mock := redismock.NewClientMock()
-				mock.ExpectSet("testredis", "testvalue", 0).SetVal("")
+				mock.ExpectIncr(versionCounter).SetVal(1)
+				mock.ExpectTxPipeline()
+				mock.ExpectSet("testredis", "testvalue", 0).SetVal("OK")
+				mock.ExpectSet(versionKey("testredis"), int64(1), 0).SetVal("OK")
+				mock.ExpectTxPipelineExec()
 				return &fields{
 					client: db,
 				}
@@ -125,6 +162,68 @@ func TestRedisConnection_Set(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "set / success / ttl",
+			fields: func() *fields {
+				db, mock := redismock.NewClientMock()
+				mock.ExpectIncr(versionCounter).SetVal(1)
+				mock.ExpectTxPipeline()
+				mock.ExpectSetArgs("testredis", "testvalue", redis.SetArgs{TTL: time.Minute}).SetVal("OK")
+				mock.ExpectSetArgs(versionKey("testredis"), int64(1), redis.SetArgs{TTL: time.Minute}).SetVal("OK")
+				mock.ExpectTxPipelineExec()
+				return &fields{
+					client: db,
+				}
+			}(),
+			args: args{
+				cmd: &RedisCommand{Key: "testredis", Value: "testvalue", TTL: durationOf(time.Minute)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "set / success / expiresAt",
+			fields: func() *fields {
+				db, mock := redismock.NewClientMock()
+				mock.ExpectIncr(versionCounter).SetVal(1)
+				mock.ExpectTxPipeline()
+				mock.ExpectSetArgs("testredis", "testvalue", redis.SetArgs{ExpireAt: expiresAt}).SetVal("OK")
+				mock.ExpectSetArgs(versionKey("testredis"), int64(1), redis.SetArgs{ExpireAt: expiresAt}).SetVal("OK")
+				mock.ExpectTxPipelineExec()
+				return &fields{
+					client: db,
+				}
+			}(),
+			args: args{
+				cmd: &RedisCommand{Key: "testredis", Value: "testvalue", ExpiresAt: &expiresAt},
+			},
+			wantErr: false,
+		},
+		{
+			name: "set / failed / ttl and expiresAt",
+			fields: func() *fields {
+				db, _ := redismock.NewClientMock()
+				return &fields{
+					client: db,
+				}
+			}(),
+			args: args{
+				cmd: &RedisCommand{Key: "testredis", Value: "testvalue", TTL: durationOf(time.Minute), ExpiresAt: &expiresAt},
+			},
+			wantErr: true,
+		},
+		{
+			name: "set / failed / negative ttl",
+			fields: func() *fields {
+				db, _ := redismock.NewClientMock()
+				return &fields{
+					client: db,
+				}
+			}(),
+			args: args{
+				cmd: &RedisCommand{Key: "testredis", Value: "testvalue", TTL: durationOf(-time.Second)},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -138,6 +237,44 @@ func TestRedisConnection_Set(t *testing.T) {
 	}
 }
 
+func TestRedisConnection_DeleteExists(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	r := &RedisConnection{client: db}
+
+	mock.ExpectExists("testredis").SetVal(1)
+	mock.ExpectTxPipeline()
+	mock.ExpectDel("testredis").SetVal(1)
+	mock.ExpectDel(versionKey("testredis")).SetVal(1)
+	mock.ExpectTxPipelineExec()
+	mock.ExpectExists("testredis").SetVal(0)
+	mock.ExpectTxPipeline()
+	mock.ExpectDel("testredis").SetVal(0)
+	mock.ExpectDel(versionKey("testredis")).SetVal(0)
+	mock.ExpectTxPipelineExec()
+	mock.ExpectTxPipeline()
+	mock.ExpectDel("broken").SetErr(errors.New("broken"))
+
+	steps := []struct {
+		name    string
+		op      func(*RedisCommand) (bool, error)
+		key     string
+		want    bool
+		wantErr bool
+	}{
+		{name: "exists", op: r.Exists, key: "testredis", want: true},
+		{name: "delete", op: r.Delete, key: "testredis", want: true},
+		{name: "exists after delete", op: r.Exists, key: "testredis", want: false},
+		{name: "delete again", op: r.Delete, key: "testredis", want: false},
+		{name: "delete / failed", op: r.Delete, key: "broken", wantErr: true},
+	}
+	for _, step := range steps {
+		got, err := step.op(&RedisCommand{Key: step.key})
+		if (err != nil) != step.wantErr || got != step.want {
+			t.Errorf("%s = %v, %v, want %v", step.name, got, err, step.want)
+		}
+	}
+}
+
 func TestInitializeRedis(t *testing.T) {
 	type args struct {
 		cfg *Database
@@ -184,3 +321,48 @@ func TestInitializeRedis(t *testing.T) {
 		})
 	}
 }
+
+func durationOf(d time.Duration) *Duration {
+	r := Duration(d)
+	return &r
+}
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "seconds", in: `60`, want: time.Minute},
+		{name: "fraction of seconds", in: `1.5`, want: 1500 * time.Millisecond},
+		{name: "duration string", in: `"1h30m"`, want: 90 * time.Minute},
+		{name: "seconds string", in: `"60"`, want: time.Minute},
+		{name: "invalid string", in: `"soon"`, wantErr: true},
+		{name: "invalid type", in: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Duration
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Duration.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if time.Duration(got) != tt.want {
+				t.Errorf("Duration.UnmarshalJSON() = %v, want %v", time.Duration(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDuration_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(&RedisCommand{Key: "a", Value: "b", TTL: durationOf(1500 * time.Millisecond)})
+	if err != nil {
+		t.Errorf("Duration.MarshalJSON() error = %v", err)
+		return
+	}
+	if want := `{"key":"a","value":"b","ttl":2}`; string(b) != want {
+		t.Errorf("Duration.MarshalJSON() = %s, want %s", b, want)
+	}
+}
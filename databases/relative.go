@@ -0,0 +1,191 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now clock which relative times are resolved against, replaced in tests
+var now = time.Now
+
+// anchors of relative time expressions, longer names first so startOfMonth
+// is not taken for an unknown anchor
+var anchors = []struct {
+	name  string
+	round byte
+}{
+	{"startOfMonth", 'M'},
+	{"startOfWeek", 'w'},
+	{"startOfYear", 'y'},
+	{"startOfDay", 'd'},
+	{"today", 'd'},
+	{"now", 0},
+}
+
+// maxOffsets upper bounds of amounts per unit, clock units are bounded so
+// they don't overflow time.Duration and calendar ones by years of dates
+var maxOffsets = map[byte]int64{
+	's': math.MaxInt64 / int64(time.Second),
+	'm': math.MaxInt64 / int64(time.Minute),
+	'h': math.MaxInt64 / int64(time.Hour),
+	'd': 366 * maxYear,
+	'w': 53 * maxYear,
+	'M': 12 * maxYear,
+	'y': maxYear,
+}
+
+// maxYear last year relative times can resolve to
+const maxYear = 9999
+
+// isRelativeTime reports whether value looks like a relative time expression
+func isRelativeTime(value string) bool {
+	for _, a := range anchors {
+		if strings.HasPrefix(value, a.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRelativeTime resolves expressions like now-7d, startOfMonth or today-1d/d
+// in given location. An expression is an anchor (now, today, startOfDay,
+// startOfWeek, startOfMonth, startOfYear) followed by any number of +n<unit>,
+// -n<unit> or /<unit> operations where unit is one of s, m, h, d, w, M, y and
+// /<unit> rounds down to the start of unit. When endOfDay is set and the last
+// operation is a rounding, time is rounded up to the last millisecond of the
+// unit so today/d as endDate covers the whole day. Expressions resolving to a
+// day like today, startOfMonth or today-1d cover their whole day too, the same
+// way a date does. Amounts overflowing their unit and times outside years
+// 1-9999 are invalid.
+func parseRelativeTime(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	t := now().In(loc)
+	rest := ""
+	found := false
+	// day is set while t is the start of a day
+	day := false
+	for _, a := range anchors {
+		if strings.HasPrefix(value, a.name) {
+			if a.round != 0 {
+				t = floorTime(t, a.round)
+				day = true
+			}
+			rest = value[len(a.name):]
+			found = true
+			break
+		}
+	}
+	if !found {
+		return time.Time{}, ErrInvalidRelativeTime
+	}
+
+	for rest != "" {
+		op := rest[0]
+		rest = rest[1:]
+		switch op {
+		case '+', '-':
+			i := 0
+			for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+				i++
+			}
+			if i == 0 || i == len(rest) {
+				return time.Time{}, ErrInvalidRelativeTime
+			}
+			n, err := strconv.Atoi(rest[:i])
+			if err != nil || int64(n) > maxOffsets[rest[i]] {
+				return time.Time{}, ErrInvalidRelativeTime
+			}
+			if op == '-' {
+				n = -n
+			}
+			if t, err = addTime(t, n, rest[i]); err != nil {
+				return time.Time{}, err
+			}
+			day = day && !isClockUnit(rest[i])
+			rest = rest[i+1:]
+		case '/':
+			if rest == "" || !isTimeUnit(rest[0]) {
+				return time.Time{}, ErrInvalidRelativeTime
+			}
+			unit := rest[0]
+			rest = rest[1:]
+			t = floorTime(t, unit)
+			day = !isClockUnit(unit)
+			if endOfDay && rest == "" {
+				t, _ = addTime(t, 1, unit)
+				t = t.Add(-time.Millisecond)
+				day = false
+			}
+		default:
+			return time.Time{}, ErrInvalidRelativeTime
+		}
+	}
+	if endOfDay && day {
+		t = t.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+	if t.Year() < 1 || t.Year() > maxYear {
+		return time.Time{}, ErrInvalidRelativeTime
+	}
+	return t, nil
+}
+
+func isTimeUnit(unit byte) bool {
+	return strings.IndexByte("smhdwMy", unit) >= 0
+}
+
+// isClockUnit reports whether unit is shorter than a day
+func isClockUnit(unit byte) bool {
+	return strings.IndexByte("smh", unit) >= 0
+}
+
+// addTime adds n units to t, days and larger units are calendar units of t's location
+func addTime(t time.Time, n int, unit byte) (time.Time, error) {
+	switch unit {
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), nil
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case 'h':
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'M':
+		return t.AddDate(0, n, 0), nil
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	}
+	return time.Time{}, ErrInvalidRelativeTime
+}
+
+// floorTime rounds t down to the start of unit in t's location, weeks start on monday
+func floorTime(t time.Time, unit byte) time.Time {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	loc := t.Location()
+	switch unit {
+	case 's':
+		return time.Date(y, mo, d, h, mi, s, 0, loc)
+	case 'm':
+		return time.Date(y, mo, d, h, mi, 0, 0, loc)
+	case 'h':
+		return time.Date(y, mo, d, h, 0, 0, 0, loc)
+	case 'd':
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc)
+	case 'w':
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, loc)
+	case 'M':
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+	case 'y':
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	}
+	return t
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseRelativeTime(t *testing.T) {
+	// wednesday
+	fixed := time.Date(2023, 3, 15, 14, 30, 45, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	istanbul, _ := time.LoadLocation("Europe/Istanbul")
+	tests := []struct {
+		name     string
+		value    string
+		loc      *time.Location
+		endOfDay bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{name: "now", value: "now", loc: time.UTC, want: fixed},
+		{name: "now-7d", value: "now-7d", loc: time.UTC, want: fixed.AddDate(0, 0, -7)},
+		{name: "now+2h", value: "now+2h", loc: time.UTC, want: fixed.Add(2 * time.Hour)},
+		{name: "today", value: "today", loc: time.UTC, want: time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "today / timezone", value: "today", loc: istanbul, want: time.Date(2023, 3, 15, 0, 0, 0, 0, istanbul)},
+		{name: "startOfWeek", value: "startOfWeek", loc: time.UTC, want: time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{name: "startOfMonth", value: "startOfMonth", loc: time.UTC, want: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "startOfYear-1y", value: "startOfYear-1y", loc: time.UTC, want: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "today-1d/d", value: "today-1d/d", loc: time.UTC, want: time.Date(2023, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{
+			name:     "today-1d/d / end of day",
+			value:    "today-1d/d",
+			loc:      time.UTC,
+			endOfDay: true,
+			want:     time.Date(2023, 3, 14, 23, 59, 59, 999000000, time.UTC),
+		},
+		{
+			name:     "now-1M/M / end of month",
+			value:    "now-1M/M",
+			loc:      time.UTC,
+			endOfDay: true,
+			want:     time.Date(2023, 2, 28, 23, 59, 59, 999000000, time.UTC),
+		},
+		{
+			name:     "today / end of day",
+			value:    "today",
+			loc:      time.UTC,
+			endOfDay: true,
+			want:     time.Date(2023, 3, 15, 23, 59, 59, 999000000, time.UTC),
+		},
+		{
+			name:     "today-1d / end of day",
+			value:    "today-1d",
+			loc:      istanbul,
+			endOfDay: true,
+			want:     time.Date(2023, 3, 14, 23, 59, 59, 999000000, istanbul),
+		},
+		{
+			name:     "startOfMonth / end of day",
+			value:    "startOfMonth",
+			loc:      time.UTC,
+			endOfDay: true,
+			want:     time.Date(2023, 3, 1, 23, 59, 59, 999000000, time.UTC),
+		},
+		{
+			name:     "today+12h / end of day",
+			value:    "today+12h",
+			loc:      time.UTC,
+			endOfDay: true,
+			want:     time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{name: "now / end of day", value: "now", loc: time.UTC, endOfDay: true, want: fixed},
+		{name: "unknown anchor", value: "yesterday", loc: time.UTC, wantErr: true},
+		{name: "missing amount", value: "now-d", loc: time.UTC, wantErr: true},
+		{name: "missing unit", value: "now-7", loc: time.UTC, wantErr: true},
+		{name: "unknown unit", value: "now-7x", loc: time.UTC, wantErr: true},
+		{name: "unknown rounding", value: "now/q", loc: time.UTC, wantErr: true},
+		{name: "hours overflowing duration", value: "now-3000000h", loc: time.UTC, wantErr: true},
+		{name: "seconds overflowing duration", value: "now-9223372036854775807s", loc: time.UTC, wantErr: true},
+		{name: "years before first year", value: "now-99999999999y", loc: time.UTC, wantErr: true},
+		{name: "weeks after last year", value: "now+500000w", loc: time.UTC, wantErr: true},
+		{name: "largest hours", value: "now-2562047h", loc: time.UTC, want: fixed.Add(-2562047 * time.Hour)},
+		{name: "trailing garbage", value: "now-1d abc", loc: time.UTC, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRelativeTime(tt.value, tt.loc, tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRelativeTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseRelativeTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rollupStateCollection watermarks of rollups by rollup collection name
+const rollupStateCollection = "rollups"
+
+// Rollup daily rollup of records collection, collection defaults to
+// records collection suffixed with _daily, interval in seconds and
+// lookback in days
+type Rollup struct {
+	Collection string `json:"collection"`
+	Interval   int    `json:"interval"`
+	Lookback   int    `json:"lookback"`
+}
+
+const (
+	defaultRollupInterval = 300
+	defaultRollupLookback = 1
+)
+
+func (r *Rollup) validate() error {
+	if r.Interval < 0 || r.Lookback < 0 {
+		return ErrInvalidRollup
+	}
+	return nil
+}
+
+// rollup sums of count per key and UTC day, days before watermark are
+// complete. Days in lookback window before watermark are recomputed on
+// every refresh so records ingested late are included, records older
+// than that are only reflected after rollup collection is rebuilt.
+type rollup struct {
+	collection *mongo.Collection
+	state      *mongo.Collection
+	interval   time.Duration
+	lookback   int
+
+	mu        sync.RWMutex
+	watermark time.Time
+}
+
+// collectionName name of rollup collection of records collection
+func (r *Rollup) collectionName(records string) string {
+	if r.Collection == "" {
+		return records + "_daily"
+	}
+	return r.Collection
+}
+
+// RollupKey identifies rollup of dataset, datasets with the same key share
+// their rollup, empty when rollup is not configured
+func (d *Database) RollupKey() string {
+	if d.Rollup == nil {
+		return ""
+	}
+	return d.CollectionKey() + "/" + d.Rollup.collectionName(d.collectionName())
+}
+
+// rollups rollups by RollupKey, datasets sharing a rollup share its
+// watermark so only one of them needs to refresh it
+var rollups = map[string]*rollup{}
+var rollupsMu sync.Mutex
+
+func sharedRollup(key string, database *mongo.Database, collection string, cfg *Rollup) *rollup {
+	rollupsMu.Lock()
+	defer rollupsMu.Unlock()
+	if r, ok := rollups[key]; ok {
+		return r
+	}
+	r := newRollup(database, collection, cfg)
+	rollups[key] = r
+	return r
+}
+
+func newRollup(database *mongo.Database, collection string, cfg *Rollup) *rollup {
+	name := cfg.collectionName(collection)
+	r := &rollup{
+		collection: database.Collection(name),
+		state:      database.Collection(rollupStateCollection),
+		interval:   time.Duration(cfg.Interval) * time.Second,
+		lookback:   cfg.Lookback,
+	}
+	if r.interval == 0 {
+		r.interval = defaultRollupInterval * time.Second
+	}
+	if r.lookback == 0 {
+		r.lookback = defaultRollupLookback
+	}
+	return r
+}
+
+// Watermark end of complete days in rollup, zero until first refresh
+func (r *rollup) Watermark() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.watermark
+}
+
+func (r *rollup) setWatermark(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watermark = t
+}
+
+type rollupState struct {
+	Watermark time.Time `bson:"watermark"`
+}
+
+// RunRollup loads watermark of rollup and refreshes it every interval until
+// ctx is done, returns immediately when rollup is not configured
+func (c *mClient) RunRollup(ctx context.Context) {
+	if c.rollup == nil {
+		return
+	}
+	state := &rollupState{}
+	err := c.rollup.state.FindOne(ctx, bson.D{{"_id", c.rollup.collection.Name()}}).Decode(state)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("[Rollup] %s: can't load watermark: %s", c.rollup.collection.Name(), err.Error())
+	}
+	c.rollup.setWatermark(state.Watermark.UTC())
+	_, err = c.rollup.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{"day", 1}, {"key", 1}}})
+	if err != nil {
+		log.Printf("[Rollup] %s: can't create index: %s", c.rollup.collection.Name(), err.Error())
+	}
+
+	ticker := time.NewTicker(c.rollup.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.refreshRollup(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[Rollup] %s: refresh failed: %s", c.rollup.collection.Name(), err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshRollup merges days from lookback window before watermark up to
+// today into rollup, today is never rolled up since it is not complete
+func (c *mClient) refreshRollup(ctx context.Context) error {
+	fields := c.fields.withDefaults()
+	watermark := c.rollup.Watermark()
+	to := floorTime(now().UTC(), 'd')
+
+	createdAt := bson.D{{"$lt", to}}
+	if !watermark.IsZero() {
+		createdAt = append(createdAt, bson.E{"$gte", watermark.AddDate(0, 0, -c.rollup.lookback)})
+	}
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{{fields.CreatedAt, createdAt}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{
+				{"key", "$" + fields.Key},
+				{"day", bson.D{{"$dateTrunc", bson.D{{"date", "$" + fields.CreatedAt}, {"unit", "day"}}}}},
+			}},
+			{"count", bson.D{{"$sum", "$" + fields.Count}}},
+			{"docCount", bson.D{{"$sum", 1}}},
+			{"createdAt", bson.D{{"$min", "$" + fields.CreatedAt}}},
+		}}},
+		{{"$set", bson.D{{"key", "$_id.key"}, {"day", "$_id.day"}}}},
+		{{"$merge", bson.D{
+			{"into", c.rollup.collection.Name()},
+			{"on", "_id"},
+			{"whenMatched", "replace"},
+			{"whenNotMatched", "insert"},
+		}}},
+	}
+	cursor, err := c.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	cursor.Close(context.Background())
+
+	_, err = c.rollup.state.UpdateOne(ctx,
+		bson.D{{"_id", c.rollup.collection.Name()}},
+		bson.D{{"$set", bson.D{{"watermark", to}}}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	c.rollup.setWatermark(to)
+	return nil
+}
+
+// rollupRange whole UTC days of filter's date range which are in rollup,
+// filters of single records, buckets, metrics and other time zones are
+// only answered from records. Zero from means the first day of rollup.
+func (c *mClient) rollupRange(f *MongodbFilter) (time.Time, time.Time, bool) {
+	if c.rollup == nil || f.MinCount != nil || f.MaxCount != nil || f.Bucket != "" || len(f.Metrics) > 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	if f.Timezone != "" && f.Timezone != "UTC" {
+		return time.Time{}, time.Time{}, false
+	}
+	to := c.rollup.Watermark()
+	if to.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+
+	var from time.Time
+	if f.StartDate != nil {
+		start := f.StartDate.Time().UTC()
+		if from = floorTime(start, 'd'); from.Before(start) {
+			from = from.AddDate(0, 0, 1)
+		}
+	}
+	if f.EndDate != nil {
+		// end date is inclusive, a day is whole when end is its last millisecond
+		if end := floorTime(f.EndDate.Time().UTC().Add(time.Millisecond), 'd'); end.Before(to) {
+			to = end
+		}
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// rollupStages groups records of range edges together with rollup days of
+// [from, to), grouped documents are in the same shape groupStages returns
+func (c *mClient) rollupStages(f *MongodbFilter, fields Fields, from, to time.Time) mongo.Pipeline {
+	// records after rollup days, and before them when start is not a day
+	edges := bson.A{}
+	if f.StartDate != nil && f.StartDate.Time().Before(from) {
+		edges = append(edges, bson.D{{fields.CreatedAt, bson.D{{"$gte", f.StartDate.Time()}, {"$lt", from}}}})
+	}
+	after := bson.D{{"$gte", to}}
+	if f.EndDate != nil {
+		after = append(after, bson.E{"$lte", f.EndDate.Time()})
+	}
+	edges = append(edges, bson.D{{fields.CreatedAt, after}})
+
+	days := bson.D{{"$lt", to}}
+	if !from.IsZero() {
+		days = bson.D{{"$gte", from}, {"$lt", to}}
+	}
+	rollupMatch := append([]bson.M{{"day": days}}, keyFilters(f, "key")...)
+
+	return mongo.Pipeline{
+		{{"$match", bson.D{{"$and", append([]bson.M{{"$or": edges}}, keyFilters(f, fields.Key)...)}}}},
+		{{"$project", bson.D{
+			{"_id", 0},
+			{"key", "$" + fields.Key},
+			{"count", "$" + fields.Count},
+			{"docCount", bson.D{{"$literal", 1}}},
+			{"createdAt", "$" + fields.CreatedAt},
+		}}},
+		{{"$unionWith", bson.D{
+			{"coll", c.rollup.collection.Name()},
+			{"pipeline", bson.A{
+				bson.D{{"$match", bson.D{{"$and", rollupMatch}}}},
+				bson.D{{"$project", bson.D{{"_id", 0}, {"key", 1}, {"count", 1}, {"docCount", 1}, {"createdAt", 1}}}},
+			}},
+		}}},
+		{{"$group", bson.D{
+			{"_id", "$key"},
+			{"totalCount", bson.D{{"$sum", "$count"}}},
+			{"createdAt", bson.D{{"$min", "$createdAt"}}},
+			{"docCount", bson.D{{"$sum", "$docCount"}}},
+		}}},
+	}
+}
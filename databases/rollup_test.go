@@ -0,0 +1,200 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Test_mClient_rollupRange(t *testing.T) {
+	day := func(s string) time.Time {
+		tm, _ := time.Parse(time.RFC3339Nano, s)
+		return tm
+	}
+	at := func(s string) *Time {
+		t := Time(day(s))
+		return &t
+	}
+	one := 1
+	c := &mClient{rollup: &rollup{watermark: day("2023-03-01T00:00:00Z")}}
+
+	tests := []struct {
+		name     string
+		f        *MongodbFilter
+		wantFrom time.Time
+		wantTo   time.Time
+		wantOK   bool
+	}{
+		{
+			name:   "open range",
+			f:      &MongodbFilter{},
+			wantTo: day("2023-03-01T00:00:00Z"), wantOK: true,
+		},
+		{
+			name:     "whole days",
+			f:        &MongodbFilter{StartDate: at("2023-01-01T00:00:00Z"), EndDate: at("2023-01-31T23:59:59.999Z")},
+			wantFrom: day("2023-01-01T00:00:00Z"), wantTo: day("2023-02-01T00:00:00Z"), wantOK: true,
+		},
+		{
+			name:     "partial edges",
+			f:        &MongodbFilter{StartDate: at("2023-01-01T10:00:00Z"), EndDate: at("2023-01-31T10:00:00Z")},
+			wantFrom: day("2023-01-02T00:00:00Z"), wantTo: day("2023-01-31T00:00:00Z"), wantOK: true,
+		},
+		{
+			name:     "end after watermark",
+			f:        &MongodbFilter{StartDate: at("2023-02-01T00:00:00Z"), EndDate: at("2023-04-01T00:00:00Z")},
+			wantFrom: day("2023-02-01T00:00:00Z"), wantTo: day("2023-03-01T00:00:00Z"), wantOK: true,
+		},
+		{name: "less than a day", f: &MongodbFilter{StartDate: at("2023-01-01T10:00:00Z"), EndDate: at("2023-01-02T10:00:00Z")}},
+		{name: "after watermark", f: &MongodbFilter{StartDate: at("2023-03-01T00:00:00Z")}},
+		{name: "record count filter", f: &MongodbFilter{MinCount: &one}},
+		{name: "bucket", f: &MongodbFilter{Bucket: BucketDay}},
+		{name: "metrics", f: &MongodbFilter{Metrics: []string{MetricSum}}},
+		{name: "time zone", f: &MongodbFilter{Timezone: "Europe/Istanbul"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, ok := c.rollupRange(tt.f)
+			if ok != tt.wantOK || !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("mClient.rollupRange() = %v, %v, %v, want %v, %v, %v", from, to, ok, tt.wantFrom, tt.wantTo, tt.wantOK)
+			}
+		})
+	}
+
+	if _, _, ok := (&mClient{}).rollupRange(&MongodbFilter{}); ok {
+		t.Errorf("mClient.rollupRange() without rollup = true, want false")
+	}
+	if _, _, ok := (&mClient{rollup: &rollup{}}).rollupRange(&MongodbFilter{}); ok {
+		t.Errorf("mClient.rollupRange() before first refresh = true, want false")
+	}
+}
+
+func Test_mClient_pipeline_rollup(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("pipeline", func(mt *mtest.T) {
+		c := &mClient{collection: mt.Coll, rollup: &rollup{
+			collection: mt.DB.Collection("records_daily"),
+			watermark:  time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+		}}
+		got, err := c.pipeline(&MongodbFilter{Mode: ModeSummary})
+		if err != nil {
+			t.Errorf("mClient.pipeline() error = %v", err)
+			return
+		}
+		stages := []string{}
+		for _, stage := range got {
+			stages = append(stages, stage[0].Key)
+		}
+		want := []string{"$match", "$project", "$unionWith", "$group", "$group"}
+		if len(stages) != len(want) {
+			t.Errorf("mClient.pipeline() stages = %v, want %v", stages, want)
+			return
+		}
+		for i := range want {
+			if stages[i] != want[i] {
+				t.Errorf("mClient.pipeline() stages = %v, want %v", stages, want)
+			}
+		}
+	})
+}
+
+func Test_mClient_refreshRollup(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2023, 3, 2, 15, 0, 0, 0, time.UTC) }
+
+	mt.RunOpts("refresh", mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.records", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{"n", 1}, bson.E{"nModified", 1}),
+		)
+		c := &mClient{
+			client:     mt.Client,
+			database:   mt.DB,
+			collection: mt.Coll,
+			rollup:     newRollup(mt.DB, "records", &Rollup{}),
+		}
+		c.rollup.setWatermark(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC))
+
+		if err := c.refreshRollup(context.Background()); err != nil {
+			t.Errorf("mClient.refreshRollup() error = %v", err)
+			return
+		}
+		if want := time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC); !c.rollup.Watermark().Equal(want) {
+			t.Errorf("rollup.Watermark() = %v, want %v", c.rollup.Watermark(), want)
+		}
+
+		started := mt.GetStartedEvent()
+		match, err := started.Command.LookupErr("pipeline", "0", "$match", "created_at", "$gte")
+		if err != nil {
+			t.Errorf("mClient.refreshRollup() pipeline has no lookback: %v", err)
+			return
+		}
+		if want := time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC); !match.Time().Equal(want) {
+			t.Errorf("mClient.refreshRollup() lookback = %v, want %v", match.Time(), want)
+		}
+		if into, err := started.Command.LookupErr("pipeline", "3", "$merge", "into"); err != nil || into.StringValue() != "records_daily" {
+			t.Errorf("mClient.refreshRollup() merge into = %v, want records_daily", into)
+		}
+	})
+}
+
+func TestDatabase_RollupKey(t *testing.T) {
+	records := &Database{Conn: "mongodb://a", Name: "test", Rollup: &Rollup{}}
+	tests := []struct {
+		name  string
+		d     *Database
+		other *Database
+		same  bool
+	}{
+		{name: "no rollup", d: &Database{Conn: "mongodb://a", Name: "test"}},
+		{name: "same collection", d: records, other: &Database{Conn: "mongodb://a", Name: "test", Collection: "records", Dataset: "other", Rollup: &Rollup{Collection: "records_daily"}}, same: true},
+		{name: "other rollup", d: records, other: &Database{Conn: "mongodb://a", Name: "test", Rollup: &Rollup{Collection: "hourly"}}},
+		{name: "other collection", d: records, other: &Database{Conn: "mongodb://a", Name: "test", Collection: "events", Rollup: &Rollup{Collection: "records_daily"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.other == nil {
+				if got := tt.d.RollupKey(); got != "" {
+					t.Errorf("Database.RollupKey() = %s, want empty", got)
+				}
+				return
+			}
+			if got := tt.d.RollupKey() == tt.other.RollupKey(); got != tt.same {
+				t.Errorf("Database.RollupKey() %s, %s same = %v, want %v", tt.d.RollupKey(), tt.other.RollupKey(), got, tt.same)
+			}
+		})
+	}
+}
+
+func Test_sharedRollup(t *testing.T) {
+	defer func() { rollups = map[string]*rollup{} }()
+	// never connected, rollups only keep handles of collections
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := client.Database("test")
+	first := sharedRollup("a", database, "records", &Rollup{Collection: "daily"})
+	first.setWatermark(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	if got := sharedRollup("a", database, "records", &Rollup{Collection: "daily"}); got != first {
+		t.Errorf("sharedRollup() = %p, want %p", got, first)
+	}
+	if got := sharedRollup("b", database, "records", &Rollup{Collection: "daily"}); got == first {
+		t.Errorf("sharedRollup() of other key = %p, want a new rollup", got)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ModeRecords = "records"
+	ModeCount   = "count"
+	ModeSummary = "summary"
+)
+
+// MongodbSummary totals of all keys which match the filter
+type MongodbSummary struct {
+	Keys       int `bson:"keys" json:"keys"`
+	TotalCount int `bson:"totalCount" json:"totalCount"`
+	DocCount   int `bson:"docCount" json:"docCount"`
+}
+
+// countResult document of $count stage
+type countResult struct {
+	Keys int `bson:"keys"`
+}
+
+// totalStages groups keys only to apply total count filters, then counts
+// groups or sums them into a single summary document. Buckets, metrics,
+// sort and pages are meaningless for totals so they are never computed.
+func totalStages(f *MongodbFilter, fields Fields) []bson.D {
+	group := bson.D{
+		{"_id", "$" + fields.Key},
+		{"totalCount", bson.D{{"$sum", "$" + fields.Count}}},
+	}
+	if f.Mode == ModeSummary {
+		group = append(group, bson.E{"docCount", bson.D{{"$sum", 1}}})
+	}
+	stages := []bson.D{{{"$group", group}}}
+	stages = append(stages, havingStages(f)...)
+
+	return append(stages, totalsStage(f))
+}
+
+// totalsStage counts grouped keys or sums them into a summary
+func totalsStage(f *MongodbFilter) bson.D {
+	if f.Mode == ModeCount {
+		return bson.D{{"$count", "keys"}}
+	}
+	return bson.D{{"$group", bson.D{
+		{"_id", nil},
+		{"keys", bson.D{{"$sum", 1}}},
+		{"totalCount", bson.D{{"$sum", "$totalCount"}}},
+		{"docCount", bson.D{{"$sum", "$docCount"}}},
+	}}}
+}
+
+// fetchTotals runs count or summary pipeline, both return at most one
+// document and none when nothing matches
+func (c *mClient) fetchTotals(ctx context.Context, f *MongodbFilter, pipeline mongo.Pipeline) (*MongodbResult, error) {
+	cursor, err := c.collection.Aggregate(ctx, pipeline, c.aggregateOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(context.Background())
+
+	found := cursor.Next(ctx)
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.Mode == ModeCount {
+		count := &countResult{}
+		if found {
+			if err := cursor.Decode(count); err != nil {
+				return nil, err
+			}
+		}
+		return &MongodbResult{Count: &count.Keys}, nil
+	}
+	summary := &MongodbSummary{}
+	if found {
+		if err := cursor.Decode(summary); err != nil {
+			return nil, err
+		}
+	}
+	return &MongodbResult{Summary: summary}, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_mClient_Fetch_totals(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	intPtr := func(i int) *int {
+		return &i
+	}
+	respond := func(docs ...bson.D) func(mt *mtest.T) {
+		return func(mt *mtest.T) {
+			first := mtest.CreateCursorResponse(0, "test.records", mtest.FirstBatch, docs...)
+			mt.AddMockResponses(first)
+		}
+	}
+
+	tests := []struct {
+		name string
+		f    *MongodbFilter
+		mock func(mt *mtest.T)
+		want *MongodbResult
+	}{
+		{
+			name: "count",
+			f:    &MongodbFilter{Mode: ModeCount},
+			mock: respond(bson.D{{"keys", 3}}),
+			want: &MongodbResult{Count: intPtr(3)},
+		},
+		{
+			name: "count / nothing matches",
+			f:    &MongodbFilter{Mode: ModeCount},
+			mock: respond(),
+			want: &MongodbResult{Count: intPtr(0)},
+		},
+		{
+			name: "summary",
+			f:    &MongodbFilter{Mode: ModeSummary},
+			mock: respond(bson.D{{"_id", nil}, {"keys", 2}, {"totalCount", 30}, {"docCount", 5}}),
+			want: &MongodbResult{Summary: &MongodbSummary{Keys: 2, TotalCount: 30, DocCount: 5}},
+		},
+		{
+			name: "summary / nothing matches",
+			f:    &MongodbFilter{Mode: ModeSummary},
+			mock: respond(),
+			want: &MongodbResult{Summary: &MongodbSummary{}},
+		},
+	}
+	for _, tt := range tests {
+		mt.RunOpts(tt.name, mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+			tt.mock(mt)
+			c := &mClient{
+				client:     mt.Client,
+				database:   mt.DB,
+				collection: mt.Coll,
+			}
+
+			got, err := c.Fetch(context.Background(), tt.f)
+			if err != nil {
+				t.Errorf("mClient.Fetch() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mClient.Fetch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_totalStages(t *testing.T) {
+	minTotal := 2
+	tests := []struct {
+		name string
+		f    *MongodbFilter
+		want []bson.D
+	}{
+		{
+			name: "count",
+			f:    &MongodbFilter{Mode: ModeCount, Bucket: BucketDay, Metrics: []string{MetricSum}},
+			want: []bson.D{
+				{{"$group", bson.D{{"_id", "$key"}, {"totalCount", bson.D{{"$sum", "$count"}}}}}},
+				{{"$count", "keys"}},
+			},
+		},
+		{
+			name: "summary with total count filter",
+			f:    &MongodbFilter{Mode: ModeSummary, MinTotalCount: &minTotal},
+			want: []bson.D{
+				{{"$group", bson.D{
+					{"_id", "$key"},
+					{"totalCount", bson.D{{"$sum", "$count"}}},
+					{"docCount", bson.D{{"$sum", 1}}},
+				}}},
+				{{"$match", bson.D{{"totalCount", bson.D{{"$gte", 2}}}}}},
+				{{"$group", bson.D{
+					{"_id", nil},
+					{"keys", bson.D{{"$sum", 1}}},
+					{"totalCount", bson.D{{"$sum", "$totalCount"}}},
+					{"docCount", bson.D{{"$sum", "$docCount"}}},
+				}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := totalStages(tt.f, defaultFields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("totalStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
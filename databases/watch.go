@@ -0,0 +1,100 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongodbEvent record inserted into records collection, ID is the resume
+// token of the event which clients send back to continue after it
+type MongodbEvent struct {
+	ID        string `json:"-"`
+	Key       string `json:"key"`
+	Count     int    `json:"count"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// MongodbEventStream events of records collection in insertion order
+type MongodbEventStream interface {
+	// Next blocks until next event, ctx is done or stream fails
+	Next(ctx context.Context) (*MongodbEvent, error)
+	Close(ctx context.Context) error
+}
+
+// recordTimeLayout layout of $dateToString, so event times look like
+// createdAt of records
+const recordTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// Watch opens a change stream of inserted records matching key and count
+// filters of f, other filters are not applicable to single records. Stream
+// starts after the event of resumeAfter when given, now otherwise.
+func (c *mClient) Watch(ctx context.Context, f *MongodbFilter, resumeAfter string) (MongodbEventStream, error) {
+	opts := options.ChangeStream()
+	if resumeAfter != "" {
+		token, err := base64.RawURLEncoding.DecodeString(resumeAfter)
+		if err != nil || bson.Raw(token).Validate() != nil {
+			return nil, ErrInvalidResumeToken
+		}
+		opts.SetResumeAfter(bson.Raw(token))
+	}
+
+	fields := c.fields.withDefaults()
+	match := append([]bson.M{{"operationType": "insert"}}, keyFilters(f, "fullDocument."+fields.Key)...)
+	if f.MinCount != nil || f.MaxCount != nil {
+		m := bson.M{}
+		if f.MinCount != nil {
+			m["$gte"] = *f.MinCount
+		}
+		if f.MaxCount != nil {
+			m["$lte"] = *f.MaxCount
+		}
+		match = append(match, bson.M{"fullDocument." + fields.Count: m})
+	}
+	pipeline := mongo.Pipeline{{{"$match", bson.D{{"$and", match}}}}}
+
+	cs, err := c.collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &changeStream{cs: cs, fields: fields}, nil
+}
+
+type changeStream struct {
+	cs     *mongo.ChangeStream
+	fields Fields
+}
+
+func (s *changeStream) Next(ctx context.Context) (*MongodbEvent, error) {
+	if !s.cs.Next(ctx) {
+		if err := s.cs.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ctx.Err()
+	}
+	doc := s.cs.Current
+	event := &MongodbEvent{ID: base64.RawURLEncoding.EncodeToString(doc.Lookup("_id").Document())}
+	if key, ok := doc.Lookup("fullDocument", s.fields.Key).StringValueOK(); ok {
+		event.Key = key
+	}
+	if count, ok := doc.Lookup("fullDocument", s.fields.Count).AsInt64OK(); ok {
+		event.Count = int(count)
+	}
+	if createdAt, ok := doc.Lookup("fullDocument", s.fields.CreatedAt).DateTimeOK(); ok {
+		event.CreatedAt = time.UnixMilli(createdAt).UTC().Format(recordTimeLayout)
+	}
+	return event, nil
+}
+
+func (s *changeStream) Close(ctx context.Context) error {
+	return s.cs.Close(ctx)
+}
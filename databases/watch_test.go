@@ -0,0 +1,86 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package databases
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_mClient_Watch(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	token := bson.D{{"_data", "8263B3"}}
+	tokenBytes, _ := bson.Marshal(token)
+	createdAt := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	mt.RunOpts("insert event", mtest.NewOptions().DatabaseName("test").CollectionName("records"), func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "test.records", mtest.FirstBatch, bson.D{
+			{"_id", token},
+			{"operationType", "insert"},
+			{"fullDocument", bson.D{
+				{"_id", primitive.NewObjectID()},
+				{"event", "a"},
+				{"value", int32(5)},
+				{"ts", primitive.NewDateTimeFromTime(createdAt)},
+			}},
+		}), mtest.CreateSuccessResponse())
+		c := &mClient{
+			client:     mt.Client,
+			database:   mt.DB,
+			collection: mt.Coll,
+			fields:     &Fields{Key: "event", Count: "value", CreatedAt: "ts"},
+		}
+
+		minCount := 2
+		stream, err := c.Watch(context.Background(), &MongodbFilter{Keys: []string{"a"}, MinCount: &minCount}, "")
+		if err != nil {
+			t.Errorf("mClient.Watch() error = %v", err)
+			return
+		}
+		defer stream.Close(context.Background())
+		started := mt.GetStartedEvent()
+		if _, err := started.Command.LookupErr("pipeline", "1", "$match", "$and", "1", "fullDocument.event", "$in"); err != nil {
+			t.Errorf("mClient.Watch() pipeline has no key filter: %v", started.Command)
+		}
+		if _, err := started.Command.LookupErr("pipeline", "1", "$match", "$and", "2", "fullDocument.value", "$gte"); err != nil {
+			t.Errorf("mClient.Watch() pipeline has no count filter: %v", started.Command)
+		}
+
+		got, err := stream.Next(context.Background())
+		if err != nil {
+			t.Errorf("MongodbEventStream.Next() error = %v", err)
+			return
+		}
+		want := &MongodbEvent{
+			ID:        base64.RawURLEncoding.EncodeToString(tokenBytes),
+			Key:       "a",
+			Count:     5,
+			CreatedAt: "2023-01-02T10:00:00.000Z",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("MongodbEventStream.Next() = %+v, want %+v", got, want)
+		}
+	})
+
+	mt.Run("invalid resume token", func(mt *mtest.T) {
+		c := &mClient{client: mt.Client, database: mt.DB, collection: mt.Coll}
+		if _, err := c.Watch(context.Background(), &MongodbFilter{}, "!!"); err != ErrInvalidResumeToken {
+			t.Errorf("mClient.Watch() error = %v, want %v", err, ErrInvalidResumeToken)
+		}
+		if _, err := c.Watch(context.Background(), &MongodbFilter{}, "AAAA"); err != ErrInvalidResumeToken {
+			t.Errorf("mClient.Watch() error = %v, want %v", err, ErrInvalidResumeToken)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"encoding/json"
+	"getircase/databases"
+	"io/ioutil"
+	"net/http"
+	"path"
+)
+
+// maxBatchCommands upper bound of commands in a single batch request
+const maxBatchCommands = 1000
+
+// maxBatchBody upper bound of batch request body size
+const maxBatchBody = 4 << 20
+
+type BatchResponse struct {
+	Results []*databases.BatchResult `json:"results"`
+}
+
+// batchClient executes batches of a store, commands of the store are
+// converted from redis commands
+type batchClient interface {
+	GetMany([]string) ([]*databases.BatchResult, error)
+	SetMany([]*databases.RedisCommand) ([]*databases.BatchResult, error)
+}
+
+// BatchHandler executes arrays of commands posted to <prefix>/batch/get and
+// <prefix>/batch/set, redis and inmemory differ only by how commands are executed
+type BatchHandler struct {
+	client batchClient
+	// expiry is false when store can't expire keys, ttl of commands is rejected
+	expiry bool
+}
+
+func NewRedisBatchHandler(client databases.Redis) *BatchHandler {
+	return &BatchHandler{client: client, expiry: true}
+}
+
+func NewInmemoryBatchHandler(client databases.Inmemory) *BatchHandler {
+	return &BatchHandler{client: &inmemoryBatch{client: client}}
+}
+
+// inmemoryBatch runs batches on inmemory store, client is nil when inmemory
+// database is not configured
+type inmemoryBatch struct {
+	client databases.Inmemory
+}
+
+func (b *inmemoryBatch) GetMany(keys []string) ([]*databases.BatchResult, error) {
+	if b.client == nil {
+		return nil, databases.ErrInmemoryInitializeFirst
+	}
+	return b.client.GetMany(keys)
+}
+
+func (b *inmemoryBatch) SetMany(cmds []*databases.RedisCommand) ([]*databases.BatchResult, error) {
+	if b.client == nil {
+		return nil, databases.ErrInmemoryInitializeFirst
+	}
+	converted := make([]*databases.InmemoryCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		converted = append(converted, &databases.InmemoryCommand{Key: cmd.Key, Value: cmd.Value})
+	}
+	return b.client.SetMany(converted)
+}
+
+func (h *BatchHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		writeError(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		writeError(rw, http.StatusUnsupportedMediaType, ErrInvalidContentType)
+		return
+	}
+
+	switch path.Base(r.URL.Path) {
+	case "get":
+		h.Get(rw, r)
+	case "set":
+		h.Set(rw, r)
+	default:
+		writeError(rw, http.StatusNotFound, ErrInvalidBatchOperation)
+	}
+}
+
+// Get reads keys of the posted commands, values are ignored
+func (h *BatchHandler) Get(rw http.ResponseWriter, r *http.Request) {
+	cmds, status, err := decodeBatch(rw, r)
+	if err != nil {
+		writeError(rw, status, err)
+		return
+	}
+
+	results, err := batchResults(cmds, func(cmd *databases.RedisCommand) error {
+		if cmd.Key == "" {
+			return ErrKeyEmpty
+		}
+		return nil
+	}, func(valid []*databases.RedisCommand) ([]*databases.BatchResult, error) {
+		keys := make([]string, 0, len(valid))
+		for _, cmd := range valid {
+			keys = append(keys, cmd.Key)
+		}
+		return h.client.GetMany(keys)
+	})
+	writeBatch(rw, results, err)
+}
+
+// Set writes the posted commands, invalid commands are reported and the
+// rest of the batch is written anyway
+func (h *BatchHandler) Set(rw http.ResponseWriter, r *http.Request) {
+	cmds, status, err := decodeBatch(rw, r)
+	if err != nil {
+		writeError(rw, status, err)
+		return
+	}
+
+	if !h.expiry {
+		for _, cmd := range cmds {
+			if cmd.TTL != nil || cmd.ExpiresAt != nil {
+				writeError(rw, http.StatusBadRequest, databases.ErrInmemoryTTLUnsupported)
+				return
+			}
+		}
+	}
+
+	results, err := batchResults(cmds, func(cmd *databases.RedisCommand) error {
+		if cmd.Key == "" {
+			return ErrKeyEmpty
+		}
+		if cmd.Value == "" {
+			return ErrValueEmpty
+		}
+		return nil
+	}, h.client.SetMany)
+	writeBatch(rw, results, err)
+}
+
+// decodeBatch reads a non empty array of commands from request body
+func decodeBatch(rw http.ResponseWriter, r *http.Request) ([]*databases.RedisCommand, int, error) {
+	f, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxBatchBody))
+	if err != nil {
+		return nil, http.StatusRequestEntityTooLarge, err
+	}
+
+	cmds := []*databases.RedisCommand{}
+	if err := json.Unmarshal(f, &cmds); err != nil || len(cmds) == 0 {
+		return nil, http.StatusBadRequest, ErrInvalidInput
+	}
+	if len(cmds) > maxBatchCommands {
+		return nil, http.StatusRequestEntityTooLarge, ErrTooManyCommands
+	}
+	for _, cmd := range cmds {
+		if cmd == nil {
+			return nil, http.StatusBadRequest, ErrInvalidInput
+		}
+	}
+
+	return cmds, http.StatusOK, nil
+}
+
+// batchResults executes valid commands with exec and places their results
+// next to errors of invalid commands, in request order
+func batchResults(cmds []*databases.RedisCommand, validate func(*databases.RedisCommand) error,
+	exec func([]*databases.RedisCommand) ([]*databases.BatchResult, error)) ([]*databases.BatchResult, error) {
+	results := make([]*databases.BatchResult, len(cmds))
+	valid := make([]*databases.RedisCommand, 0, len(cmds))
+	positions := make([]int, 0, len(cmds))
+	for idx, cmd := range cmds {
+		if err := validate(cmd); err != nil {
+			results[idx] = &databases.BatchResult{Key: cmd.Key, Error: err.Error()}
+			continue
+		}
+		valid = append(valid, cmd)
+		positions = append(positions, idx)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	executed, err := exec(valid)
+	if err != nil {
+		return nil, err
+	}
+	for idx, result := range executed {
+		results[positions[idx]] = result
+	}
+
+	return results, nil
+}
+
+// writeBatch responds multi status when any command of the batch failed
+func writeBatch(rw http.ResponseWriter, results []*databases.BatchResult, err error) {
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	b, err := json.Marshal(&BatchResponse{Results: results})
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Error != "" {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+	rw.WriteHeader(status)
+	rw.Write(b)
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"getircase/databases"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBatchHandler_ServeHTTP(t *testing.T) {
+	client := &mockRedis{
+		gm: func(keys []string) ([]*databases.BatchResult, error) {
+			results := []*databases.BatchResult{}
+			for _, key := range keys {
+				if key == "broken" {
+					return nil, errors.New("connection refused")
+				}
+				if key == "missing" {
+					results = append(results, &databases.BatchResult{Key: key, Error: "redis: nil"})
+					continue
+				}
+				results = append(results, &databases.BatchResult{Key: key, Value: "v"})
+			}
+			return results, nil
+		},
+		sm: func(cmds []*databases.RedisCommand) ([]*databases.BatchResult, error) {
+			results := []*databases.BatchResult{}
+			for _, cmd := range cmds {
+				results = append(results, &databases.BatchResult{Key: cmd.Key, Value: cmd.Value})
+			}
+			return results, nil
+		},
+	}
+	type args struct {
+		method      string
+		path        string
+		contentType string
+		body        io.Reader
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantStatus int
+	}{
+		{
+			name:       "get method",
+			args:       args{method: http.MethodGet, path: "/redis/batch/get", contentType: "application/json"},
+			want:       `{"error": "method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "wrong content type",
+			args:       args{method: http.MethodPost, path: "/redis/batch/get", contentType: "text/html"},
+			want:       `{"error": "invalid content-type"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "unknown operation",
+			args: args{method: http.MethodPost, path: "/redis/batch/incr", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"a"}]`)},
+			want:       `{"error": "invalid batch operation"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "empty array",
+			args: args{method: http.MethodPost, path: "/redis/batch/get", contentType: "application/json",
+				body: bytes.NewBufferString(`[]`)},
+			want:       `{"error": "invalid json input"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "get",
+			args: args{method: http.MethodPost, path: "/redis/batch/get", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"a"},{"key":"b"}]`)},
+			want:       `{"results":[{"key":"a","value":"v"},{"key":"b","value":"v"}]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "get / missing and empty keys",
+			args: args{method: http.MethodPost, path: "/redis/batch/get", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"a"},{"key":""},{"key":"missing"}]`)},
+			want:       `{"results":[{"key":"a","value":"v"},{"key":"","error":"key can not be empty"},{"key":"missing","error":"redis: nil"}]}`,
+			wantStatus: http.StatusMultiStatus,
+		},
+		{
+			name: "get / store error",
+			args: args{method: http.MethodPost, path: "/redis/batch/get", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"broken"}]`)},
+			want:       `{"error": "connection refused"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "set / invalid commands are reported",
+			args: args{method: http.MethodPost, path: "/redis/batch/set", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"a","value":"1"},{"key":"b"},{"key":"c","value":"3"}]`)},
+			want:       `{"results":[{"key":"a","value":"1"},{"key":"b","error":"value can not be empty"},{"key":"c","value":"3"}]}`,
+			wantStatus: http.StatusMultiStatus,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.args.method, tt.args.path, tt.args.body)
+			r.Header.Add("Content-Type", tt.args.contentType)
+			rw := httptest.NewRecorder()
+			NewRedisBatchHandler(client).ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNewInmemoryBatchHandler(t *testing.T) {
+	var got []*databases.InmemoryCommand
+	client := &mockInmemory{sm: func(cmds []*databases.InmemoryCommand) ([]*databases.BatchResult, error) {
+		got = cmds
+		return []*databases.BatchResult{{Key: "a", Value: "1"}}, nil
+	}}
+	tests := []struct {
+		name       string
+		client     databases.Inmemory
+		body       string
+		want       string
+		wantStatus int
+	}{
+		{
+			name:       "set",
+			client:     client,
+			body:       `[{"key":"a","value":"1"}]`,
+			want:       `{"results":[{"key":"a","value":"1"}]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "set / ttl",
+			client:     client,
+			body:       `[{"key":"a","value":"1"},{"key":"b","value":"2","ttl":60}]`,
+			want:       `{"error": "inmemory: ttl is not supported"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "set / not configured",
+			client:     nil,
+			body:       `[{"key":"a","value":"1"}]`,
+			want:       `{"error": "inmemory: initialize inmemory first"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			r := httptest.NewRequest(http.MethodPost, "/inmemory/batch/set", bytes.NewBufferString(tt.body))
+			r.Header.Add("Content-Type", "application/json")
+			rw := httptest.NewRecorder()
+			NewInmemoryBatchHandler(tt.client).ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && (len(got) != 1 || *got[0] != (databases.InmemoryCommand{Key: "a", Value: "1"})) {
+				t.Errorf("ServeHTTP() set %+v", got)
+			}
+		})
+	}
+}
@@ -16,3 +16,30 @@ var ErrInvalidContentType = errors.New("invalid content-type")
 var ErrInvalidRequestMethod = errors.New(strings.ToLower(http.StatusText(http.StatusMethodNotAllowed)))
 var ErrFetchError = errors.New("mongodb: fetch error")
 var ErrMarshalError = errors.New("json: marshal")
+var ErrInvalidLimit = errors.New("invalid limit")
+var ErrInvalidSortBy = errors.New("invalid sortBy")
+var ErrInvalidOrder = errors.New("invalid order")
+var ErrInvalidBucket = errors.New("invalid bucket")
+var ErrInvalidMetric = errors.New("invalid metric")
+var ErrInvalidTotalCount = errors.New("minTotalCount can not be greater than maxTotalCount")
+var ErrTooManyKeys = errors.New("too many keys")
+var ErrInvalidOrdered = errors.New("invalid ordered")
+var ErrTooManyDocuments = errors.New("too many documents")
+var ErrCountMissing = errors.New("count can not be empty")
+var ErrNegativeCount = errors.New("count can not be negative")
+var ErrInsertError = errors.New("mongodb: insert error")
+var ErrPartialInsert = errors.New("some documents are not inserted")
+var ErrInvalidTimeout = errors.New("invalid X-Request-Timeout")
+var ErrFetchTimeout = errors.New("mongodb: fetch timeout")
+var ErrInvalidMode = errors.New("invalid mode")
+var ErrTotalsNotPaginated = errors.New("limit and cursor can not be used with count or summary mode")
+var ErrExplainError = errors.New("mongodb: explain error")
+var ErrInvalidCount = errors.New("invalid minCount or maxCount")
+var ErrWatchError = errors.New("mongodb: watch error")
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+var ErrStreamNotPaginated = errors.New("limit and cursor can not be used with streamed exports")
+var ErrKeyNotFound = errors.New("key not found")
+var ErrInvalidKeysCount = errors.New("invalid count")
+var ErrValueEmpty = errors.New("value can not be empty")
+var ErrTooManyCommands = errors.New("too many commands")
+var ErrInvalidBatchOperation = errors.New("invalid batch operation")
@@ -0,0 +1,36 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"getircase/databases"
+	"net/http"
+	"strings"
+)
+
+// condition reads If-Match and If-None-Match headers of a write, nil when
+// write is unconditional
+func condition(r *http.Request) *databases.Condition {
+	ifMatch := entityTags(r.Header.Values("If-Match"))
+	ifNoneMatch := entityTags(r.Header.Values("If-None-Match"))
+	if len(ifMatch) == 0 && len(ifNoneMatch) == 0 {
+		return nil
+	}
+	return &databases.Condition{IfMatch: ifMatch, IfNoneMatch: ifNoneMatch}
+}
+
+// entityTags splits comma separated tag lists of repeated headers
+func entityTags(values []string) []string {
+	tags := []string{}
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,190 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"getircase/databases"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// heartbeatEvery interval of comments sent to keep idle connections open
+var heartbeatEvery = 15 * time.Second
+
+type mongodbEventsHandler struct {
+	client databases.MongoClient
+	// shutdown closes open streams when it is done, http server waits for
+	// them on shutdown otherwise
+	shutdown context.Context
+}
+
+func NewMongodbEventsHandler(shutdown context.Context, c databases.MongoClient) *mongodbEventsHandler {
+	return &mongodbEventsHandler{
+		client:   c,
+		shutdown: shutdown,
+	}
+}
+
+func (h *mongodbEventsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Add("Content-Type", "application/json")
+		createFailResponse(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
+		return
+	}
+
+	h.Events(rw, r)
+}
+
+// Events streams inserted records as server sent events. Records are
+// filtered by key, keyPrefix, keyPattern, minCount and maxCount query
+// parameters, key can be repeated. Every event's id is a resume token,
+// reconnecting clients send the last one as Last-Event-ID header or
+// lastEventId query parameter and receive events after it.
+func (h *mongodbEventsHandler) Events(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		rw.Header().Add("Content-Type", "application/json")
+		createFailResponse(rw, http.StatusInternalServerError, ErrStreamingUnsupported)
+		return
+	}
+	filter, err := eventsFilter(r.URL.Query())
+	if err != nil {
+		rw.Header().Add("Content-Type", "application/json")
+		createFailResponse(rw, http.StatusBadRequest, err)
+		return
+	}
+	resumeAfter := r.Header.Get("Last-Event-ID")
+	if resumeAfter == "" {
+		resumeAfter = r.URL.Query().Get("lastEventId")
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.shutdown.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	stream, err := h.client.Watch(ctx, filter, resumeAfter)
+	if err != nil {
+		rw.Header().Add("Content-Type", "application/json")
+		if err == databases.ErrInvalidResumeToken {
+			createFailResponse(rw, http.StatusBadRequest, err)
+			return
+		}
+		createFailResponse(rw, http.StatusInternalServerError, ErrWatchError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Next blocks until an event arrives, so it is read in another
+	// goroutine and writes to the client stay in this one
+	events := make(chan *databases.MongodbEvent)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	// stream is not safe for concurrent use, it is closed after the reader
+	// goroutine stops
+	defer func() {
+		cancel()
+		<-done
+		stream.Close(context.Background())
+	}()
+	go func() {
+		defer close(done)
+		for {
+			event, err := stream.Next(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatEvery)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			if ctx.Err() == nil {
+				log.Printf("[Events] stream closed: %s", err.Error())
+			}
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(rw, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event := <-events:
+			d, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(rw, "id: %s\nevent: record\ndata: %s\n\n", event.ID, d); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// eventsFilter key and count filters of events from query parameters
+func eventsFilter(q url.Values) (*databases.MongodbFilter, error) {
+	filter := &databases.MongodbFilter{
+		Keys:       q["key"],
+		KeyPrefix:  q.Get("keyPrefix"),
+		KeyPattern: q.Get("keyPattern"),
+	}
+	var err error
+	if filter.MinCount, err = queryInt(q, "minCount"); err != nil {
+		return nil, err
+	}
+	if filter.MaxCount, err = queryInt(q, "maxCount"); err != nil {
+		return nil, err
+	}
+	if filter.MinCount != nil && filter.MaxCount != nil && *filter.MinCount > *filter.MaxCount {
+		return nil, ErrInvalidCount
+	}
+	if len(filter.Keys) > maxKeys {
+		return nil, ErrTooManyKeys
+	}
+	if filter.KeyPattern != "" {
+		if err := databases.ValidateKeyPattern(filter.KeyPattern); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+func queryInt(q url.Values, name string) (*int, error) {
+	value := q.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, ErrInvalidCount
+	}
+	return &i, nil
+}
@@ -0,0 +1,190 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"getircase/databases"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockEventStream struct {
+	events []*databases.MongodbEvent
+}
+
+func (s *mockEventStream) Next(ctx context.Context) (*databases.MongodbEvent, error) {
+	if len(s.events) == 0 {
+		return nil, errors.New("closed")
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func (s *mockEventStream) Close(ctx context.Context) error {
+	return nil
+}
+
+func Test_mongodbEventsHandler_ServeHTTP(t *testing.T) {
+	events := func(f *databases.MongodbFilter, resumeAfter string) (databases.MongodbEventStream, error) {
+		return &mockEventStream{events: []*databases.MongodbEvent{
+			{ID: "t1", Key: "a", Count: 1, CreatedAt: "2023-01-02T10:00:00.000Z"},
+			{ID: "t2", Key: "b", Count: 2, CreatedAt: "2023-01-02T10:00:01.000Z"},
+		}}, nil
+	}
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		lastEventID string
+		watch       func(*databases.MongodbFilter, string) (databases.MongodbEventStream, error)
+		want        string
+		wantStatus  int
+		wantFilter  *databases.MongodbFilter
+		wantResume  string
+	}{
+		{
+			name:       "post",
+			method:     http.MethodPost,
+			path:       "/mongodb/records/events",
+			watch:      events,
+			want:       `{"code":1,"msg":"method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "invalid minCount",
+			method:     http.MethodGet,
+			path:       "/mongodb/records/events?minCount=a",
+			watch:      events,
+			want:       `{"code":1,"msg":"invalid minCount or maxCount"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid resume token",
+			method: http.MethodGet,
+			path:   "/mongodb/records/events?lastEventId=foo",
+			watch: func(f *databases.MongodbFilter, resumeAfter string) (databases.MongodbEventStream, error) {
+				return nil, databases.ErrInvalidResumeToken
+			},
+			want:       `{"code":1,"msg":"mongodb: invalid resume token"}`,
+			wantStatus: http.StatusBadRequest,
+			wantResume: "foo",
+		},
+		{
+			name:   "watch error",
+			method: http.MethodGet,
+			path:   "/mongodb/records/events",
+			watch: func(f *databases.MongodbFilter, resumeAfter string) (databases.MongodbEventStream, error) {
+				return nil, errors.New("not a replica set")
+			},
+			want:       `{"code":1,"msg":"mongodb: watch error"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:        "events",
+			method:      http.MethodGet,
+			path:        "/mongodb/records/events?key=a&key=b&minCount=1",
+			lastEventID: "t0",
+			watch:       events,
+			want: "id: t1\nevent: record\ndata: {\"key\":\"a\",\"count\":1,\"createdAt\":\"2023-01-02T10:00:00.000Z\"}\n\n" +
+				"id: t2\nevent: record\ndata: {\"key\":\"b\",\"count\":2,\"createdAt\":\"2023-01-02T10:00:01.000Z\"}\n\n",
+			wantStatus: http.StatusOK,
+			wantFilter: &databases.MongodbFilter{Keys: []string{"a", "b"}, MinCount: func() *int { i := 1; return &i }()},
+			wantResume: "t0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.lastEventID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			rw := httptest.NewRecorder()
+			var gotFilter *databases.MongodbFilter
+			var gotResume string
+			h := NewMongodbEventsHandler(context.Background(), &mockMongo{w: func(f *databases.MongodbFilter, resumeAfter string) (databases.MongodbEventStream, error) {
+				gotFilter, gotResume = f, resumeAfter
+				return tt.watch(f, resumeAfter)
+			}})
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %q, want %q", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+			if tt.wantFilter != nil && !reflect.DeepEqual(gotFilter, tt.wantFilter) {
+				t.Errorf("ServeHTTP() filter = %+v, want %+v", gotFilter, tt.wantFilter)
+			}
+			if gotResume != tt.wantResume {
+				t.Errorf("ServeHTTP() resume token = %q, want %q", gotResume, tt.wantResume)
+			}
+		})
+	}
+}
+
+// blockingEventStream blocks Next until context is done and records
+// whether Close is called while Next is running
+type blockingEventStream struct {
+	mu      sync.Mutex
+	reading bool
+	closed  bool
+	raced   bool
+	started chan struct{}
+}
+
+func (s *blockingEventStream) Next(ctx context.Context) (*databases.MongodbEvent, error) {
+	s.mu.Lock()
+	s.reading = true
+	s.mu.Unlock()
+	close(s.started)
+	<-ctx.Done()
+	s.mu.Lock()
+	s.reading = false
+	s.mu.Unlock()
+	return nil, ctx.Err()
+}
+
+func (s *blockingEventStream) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.raced = s.reading
+	return nil
+}
+
+func Test_mongodbEventsHandler_Shutdown(t *testing.T) {
+	stream := &blockingEventStream{started: make(chan struct{})}
+	shutdown, stop := context.WithCancel(context.Background())
+	h := NewMongodbEventsHandler(shutdown, &mockMongo{w: func(f *databases.MongodbFilter, resumeAfter string) (databases.MongodbEventStream, error) {
+		return stream, nil
+	}})
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/mongodb/records/events", nil))
+	}()
+	<-stream.started
+	stop()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("ServeHTTP() is not returned on shutdown")
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if !stream.closed || stream.raced {
+		t.Errorf("stream closed = %v, closed while reading = %v", stream.closed, stream.raced)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"encoding/json"
+	"getircase/databases"
+	"net/http"
+)
+
+type ExplainResponse struct {
+	Code     int             `json:"code"`
+	Msg      string          `json:"msg"`
+	Pipeline json.RawMessage `json:"pipeline,omitempty"`
+	Explain  json.RawMessage `json:"explain,omitempty"`
+}
+
+type mongodbExplainHandler struct {
+	client databases.MongoClient
+}
+
+func NewMongodbExplainHandler(c databases.MongoClient) *mongodbExplainHandler {
+	return &mongodbExplainHandler{
+		client: c,
+	}
+}
+
+func (h *mongodbExplainHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		createFailResponse(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
+		return
+	}
+	if r.Header.Get("content-type") != "application/json" {
+		createFailResponse(rw, http.StatusUnsupportedMediaType, ErrInvalidContentType)
+		return
+	}
+
+	h.Explain(rw, r)
+}
+
+// Explain accepts the same filter as records endpoint and returns the
+// pipeline generated for it with the query plan of the server
+func (h *mongodbExplainHandler) Explain(rw http.ResponseWriter, r *http.Request) {
+	filter, status, err := decodeFilter(r)
+	if err != nil {
+		createFailResponse(rw, status, err)
+		return
+	}
+
+	ctx, cancel, err := requestContext(r)
+	if err != nil {
+		createFailResponse(rw, http.StatusBadRequest, err)
+		return
+	}
+	defer cancel()
+
+	explain, err := h.client.Explain(ctx, filter)
+	if err != nil {
+		if databases.IsTimeout(err) {
+			createFailResponse(rw, http.StatusGatewayTimeout, ErrFetchTimeout)
+			return
+		}
+		createFailResponse(rw, http.StatusInternalServerError, ErrExplainError)
+		return
+	}
+	d, err := json.Marshal(&ExplainResponse{Code: 0, Msg: "success", Pipeline: explain.Pipeline, Explain: explain.Explain})
+	if err != nil {
+		createFailResponse(rw, http.StatusInternalServerError, ErrMarshalError)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(d)
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"getircase/databases"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_mongodbExplainHandler_ServeHTTP(t *testing.T) {
+	explained := func(f *databases.MongodbFilter) (*databases.MongodbExplain, error) {
+		return &databases.MongodbExplain{
+			Pipeline: json.RawMessage(`[{"$match":{}}]`),
+			Explain:  json.RawMessage(`{"ok":1}`),
+		}, nil
+	}
+	type args struct {
+		method      string
+		contentType string
+		body        io.Reader
+	}
+	tests := []struct {
+		name       string
+		explain    func(*databases.MongodbFilter) (*databases.MongodbExplain, error)
+		args       args
+		want       string
+		wantStatus int
+	}{
+		{
+			name:       "get",
+			explain:    explained,
+			args:       args{method: http.MethodGet, contentType: "application/json"},
+			want:       `{"code":1,"msg":"method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "wrong content type",
+			explain:    explained,
+			args:       args{method: http.MethodPost, contentType: "text/html"},
+			want:       `{"code":1,"msg":"invalid content-type"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "invalid filter",
+			explain:    explained,
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"sortBy":"foo"}`)},
+			want:       `{"code":1,"msg":"invalid sortBy"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "explain",
+			explain:    explained,
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"limit":10}`)},
+			want:       `{"code":0,"msg":"success","pipeline":[{"$match":{}}],"explain":{"ok":1}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "explain error",
+			explain: func(f *databases.MongodbFilter) (*databases.MongodbExplain, error) {
+				return nil, errors.New("boom")
+			},
+			args:       args{method: http.MethodPost, contentType: "application/json"},
+			want:       `{"code":1,"msg":"mongodb: explain error"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.args.method, "/mongodb/records/explain", tt.args.body)
+			r.Header.Add("Content-Type", tt.args.contentType)
+			rw := httptest.NewRecorder()
+			h := NewMongodbExplainHandler(&mockMongo{e: tt.explain})
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"getircase/databases"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	mimeJSON   = "application/json"
+	mimeNDJSON = "application/x-ndjson"
+	mimeCSV    = "text/csv"
+)
+
+// flushEvery number of rows written before flushing response to the client
+const flushEvery = 100
+
+// negotiate returns first media type in accept header which records can be
+// streamed as, json returned when there is no streaming media type
+func negotiate(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case mimeNDJSON, mimeCSV:
+			return mediaType
+		}
+	}
+	return mimeJSON
+}
+
+type recordEncoder interface {
+	header() error
+	encode(*databases.MongodbRecord) error
+	flush() error
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) header() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) encode(r *databases.MongodbRecord) error {
+	return e.enc.Encode(r)
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
+
+// csvEncoder writes a row per record, or a row per bucket of the record's
+// series when records are bucketed, requested metrics appended as columns.
+// Metrics are of the whole key, bucket rows repeat them.
+type csvEncoder struct {
+	w       *csv.Writer
+	series  bool
+	metrics []string
+}
+
+func (e *csvEncoder) header() error {
+	if e.series {
+		return e.w.Write(append([]string{"key", "bucket", "count"}, e.metrics...))
+	}
+	return e.w.Write(append([]string{"key", "createdAt", "totalCount"}, e.metrics...))
+}
+
+func (e *csvEncoder) encode(r *databases.MongodbRecord) error {
+	metrics := make([]string, 0, len(e.metrics))
+	for _, name := range e.metrics {
+		metrics = append(metrics, r.Metrics.Format(name))
+	}
+	if !e.series {
+		return e.w.Write(append([]string{r.Key, r.CreatedAt, strconv.Itoa(r.TotalCount)}, metrics...))
+	}
+	for _, b := range r.Series {
+		if err := e.w.Write(append([]string{r.Key, b.Bucket, strconv.Itoa(b.Count)}, metrics...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// stream writes records to the client while iterating mongodb cursor,
+// so the whole result is never held in memory
+func (h *mongodbHandler) stream(ctx context.Context, rw http.ResponseWriter, filter *databases.MongodbFilter, mediaType string) {
+	var enc recordEncoder = &ndjsonEncoder{enc: json.NewEncoder(rw)}
+	if mediaType == mimeCSV {
+		enc = &csvEncoder{w: csv.NewWriter(rw), series: filter.Bucket != "", metrics: filter.Metrics}
+	}
+	flusher, _ := rw.(http.Flusher)
+
+	written := 0
+	err := h.client.Stream(ctx, filter, func(record *databases.MongodbRecord) error {
+		if written == 0 {
+			rw.Header().Set("Content-Type", mediaType)
+			rw.WriteHeader(http.StatusOK)
+			if err := enc.header(); err != nil {
+				return err
+			}
+		}
+		if err := enc.encode(record); err != nil {
+			return err
+		}
+		written++
+		if written%flushEvery == 0 {
+			if err := enc.flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// status already sent, connection is aborted so the response is not
+		// terminated properly and clients see the export failed instead of a
+		// shorter one
+		if written > 0 {
+			log.Printf("[Stream] error after %d records: %s", written, err.Error())
+			panic(http.ErrAbortHandler)
+		}
+		createFetchFailResponse(rw, ctx, err)
+		return
+	}
+
+	if written == 0 {
+		rw.Header().Set("Content-Type", mediaType)
+		rw.WriteHeader(http.StatusOK)
+		if err := enc.header(); err != nil {
+			return
+		}
+	}
+	enc.flush()
+}
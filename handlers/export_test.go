@@ -0,0 +1,183 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"getircase/databases"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_negotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "empty", accept: "", want: mimeJSON},
+		{name: "any", accept: "*/*", want: mimeJSON},
+		{name: "ndjson", accept: "application/x-ndjson", want: mimeNDJSON},
+		{name: "csv with params", accept: "text/html, text/csv;q=0.9", want: mimeCSV},
+		{name: "first wins", accept: "text/csv, application/x-ndjson", want: mimeCSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiate(tt.accept); got != tt.want {
+				t.Errorf("negotiate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mongodbHandler_stream(t *testing.T) {
+	records := func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+		for _, r := range []*databases.MongodbRecord{
+			{Key: "a", CreatedAt: "2023-01-02T00:00:00.000Z", TotalCount: 1},
+			{Key: "b,c", CreatedAt: "2023-01-03T00:00:00.000Z", TotalCount: 2},
+		} {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tests := []struct {
+		name            string
+		accept          string
+		body            string
+		stream          func(*databases.MongodbFilter, func(*databases.MongodbRecord) error) error
+		want            string
+		wantContentType string
+	}{
+		{
+			name:            "ndjson",
+			accept:          mimeNDJSON,
+			stream:          records,
+			want:            "{\"key\":\"a\",\"createdAt\":\"2023-01-02T00:00:00.000Z\",\"totalCount\":1}\n{\"key\":\"b,c\",\"createdAt\":\"2023-01-03T00:00:00.000Z\",\"totalCount\":2}\n",
+			wantContentType: mimeNDJSON,
+		},
+		{
+			name:            "csv",
+			accept:          mimeCSV,
+			stream:          records,
+			want:            "key,createdAt,totalCount\na,2023-01-02T00:00:00.000Z,1\n\"b,c\",2023-01-03T00:00:00.000Z,2\n",
+			wantContentType: mimeCSV,
+		},
+		{
+			name:   "csv / bucket",
+			accept: mimeCSV,
+			body:   `{"bucket":"day"}`,
+			stream: func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+				return fn(&databases.MongodbRecord{Key: "a", TotalCount: 3, Series: []*databases.MongodbBucket{
+					{Bucket: "2023-01-02T00:00:00.000Z", Count: 1},
+					{Bucket: "2023-01-03T00:00:00.000Z", Count: 2},
+				}})
+			},
+			want:            "key,bucket,count\na,2023-01-02T00:00:00.000Z,1\na,2023-01-03T00:00:00.000Z,2\n",
+			wantContentType: mimeCSV,
+		},
+		{
+			name:   "csv / bucket / metrics",
+			accept: mimeCSV,
+			body:   `{"bucket":"day","metrics":["sum","max"]}`,
+			stream: func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+				sum, max := 3, 2
+				return fn(&databases.MongodbRecord{Key: "a", TotalCount: 3, Metrics: &databases.MongodbMetrics{Sum: &sum, Max: &max}, Series: []*databases.MongodbBucket{
+					{Bucket: "2023-01-02T00:00:00.000Z", Count: 1},
+					{Bucket: "2023-01-03T00:00:00.000Z", Count: 2},
+				}})
+			},
+			want:            "key,bucket,count,sum,max\na,2023-01-02T00:00:00.000Z,1,3,2\na,2023-01-03T00:00:00.000Z,2,3,2\n",
+			wantContentType: mimeCSV,
+		},
+		{
+			name:   "csv / metrics",
+			accept: mimeCSV,
+			body:   `{"metrics":["max","avg"]}`,
+			stream: func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+				max, avg := 2, 1.5
+				return fn(&databases.MongodbRecord{Key: "a", CreatedAt: "2023-01-02T00:00:00.000Z", TotalCount: 3, Metrics: &databases.MongodbMetrics{
+					Max: &max,
+					Avg: &avg,
+				}})
+			},
+			want:            "key,createdAt,totalCount,max,avg\na,2023-01-02T00:00:00.000Z,3,2,1.5\n",
+			wantContentType: mimeCSV,
+		},
+		{
+			name:   "csv / empty",
+			accept: mimeCSV,
+			stream: func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+				return nil
+			},
+			want:            "key,createdAt,totalCount\n",
+			wantContentType: mimeCSV,
+		},
+		{
+			name:            "csv / limit",
+			accept:          mimeCSV,
+			body:            `{"limit":1}`,
+			stream:          records,
+			want:            `{"code":1,"msg":"limit and cursor can not be used with streamed exports"}`,
+			wantContentType: mimeJSON,
+		},
+		{
+			name:            "ndjson / cursor",
+			accept:          mimeNDJSON,
+			body:            `{"cursor":"` + databases.EncodeCursor(&databases.Cursor{ID: "a", SortBy: databases.SortByKey, Order: databases.OrderAsc}) + `"}`,
+			stream:          records,
+			want:            `{"code":1,"msg":"limit and cursor can not be used with streamed exports"}`,
+			wantContentType: mimeJSON,
+		},
+		{
+			name:   "ndjson / fetch error",
+			accept: mimeNDJSON,
+			stream: func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+				return errors.New("connection refused")
+			},
+			want:            `{"code":1,"msg":"mongodb: fetch error"}`,
+			wantContentType: mimeJSON,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/mongodb/records", bytes.NewBufferString(tt.body))
+			r.Header.Add("Content-Type", "application/json")
+			r.Header.Add("Accept", tt.accept)
+			rw := httptest.NewRecorder()
+			h := NewMongodbHandler(&mockMongo{st: tt.stream})
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("stream() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if got := rw.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("stream() content type = %s, want %s", got, tt.wantContentType)
+			}
+		})
+	}
+}
+
+func Test_mongodbHandler_stream_abort(t *testing.T) {
+	stream := func(f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+		if err := fn(&databases.MongodbRecord{Key: "a"}); err != nil {
+			return err
+		}
+		return errors.New("cursor killed")
+	}
+	r := httptest.NewRequest(http.MethodPost, "/mongodb/records", bytes.NewBufferString(`{}`))
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("Accept", mimeNDJSON)
+	rw := httptest.NewRecorder()
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("stream() panic = %v, want %v", got, http.ErrAbortHandler)
+		}
+	}()
+	NewMongodbHandler(&mockMongo{st: stream}).ServeHTTP(rw, r)
+}
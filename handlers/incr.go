@@ -0,0 +1,84 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"encoding/json"
+	"getircase/databases"
+	"io/ioutil"
+	"net/http"
+)
+
+// incrementer stores which support atomic counters, redis and inmemory
+type incrementer interface {
+	Incr(*databases.IncrCommand) (*databases.IncrResult, error)
+}
+
+type IncrHandler struct {
+	client incrementer
+}
+
+func NewIncrHandler(client incrementer) *IncrHandler {
+	return &IncrHandler{client: client}
+}
+
+func (h *IncrHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		writeError(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		writeError(rw, http.StatusUnsupportedMediaType, ErrInvalidContentType)
+		return
+	}
+
+	h.Incr(rw, r)
+}
+
+// Incr increments key by given amount and responds the new value, values
+// which are not numbers conflict with the increment
+func (h *IncrHandler) Incr(rw http.ResponseWriter, r *http.Request) {
+	command := &databases.IncrCommand{}
+	f, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+	if err := json.Unmarshal(f, command); err != nil {
+		if err == databases.ErrInvalidTTL {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		writeError(rw, http.StatusBadRequest, ErrInvalidInput)
+		return
+	}
+	if command.Key == "" {
+		writeError(rw, http.StatusBadRequest, ErrKeyEmpty)
+		return
+	}
+
+	result, err := h.client.Incr(command)
+	if err != nil {
+		switch err {
+		case databases.ErrInvalidIncrement, databases.ErrInvalidTTL, databases.ErrInmemoryTTLUnsupported, databases.ErrReservedKey:
+			writeError(rw, http.StatusBadRequest, err)
+		case databases.ErrNotANumber, databases.ErrIncrementOverflow:
+			writeError(rw, http.StatusConflict, err)
+		default:
+			writeError(rw, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Write(b)
+}
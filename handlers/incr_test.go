@@ -0,0 +1,119 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"getircase/databases"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIncrHandler_ServeHTTP(t *testing.T) {
+	client := &mockRedis{n: func(cmd *databases.IncrCommand) (*databases.IncrResult, error) {
+		switch cmd.Key {
+		case "name":
+			return nil, databases.ErrNotANumber
+		case "broken":
+			return nil, errors.New("connection refused")
+		case "__version":
+			return nil, databases.ErrReservedKey
+		}
+		if cmd.By == "" {
+			cmd.By = "1"
+		}
+		return &databases.IncrResult{Key: cmd.Key, Value: cmd.By}, nil
+	}}
+	type args struct {
+		method      string
+		contentType string
+		body        io.Reader
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantStatus int
+	}{
+		{
+			name:       "get",
+			args:       args{method: http.MethodGet, contentType: "application/json"},
+			want:       `{"error": "method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "wrong content type",
+			args:       args{method: http.MethodPost, contentType: "text/html"},
+			want:       `{"error": "invalid content-type"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "invalid by",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"hits","by":"abc"}`)},
+			want:       `{"error": "invalid json input"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid ttl",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"hits","ttl":"soon"}`)},
+			want:       `{"error": "redis: invalid ttl"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty key",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"by":1}`)},
+			want:       `{"error": "key can not be empty"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "reserved key",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"__version"}`)},
+			want:       `{"error": "redis: key is reserved"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "default increment",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"hits"}`)},
+			want:       `{"key":"hits","value":1}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "float increment",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"rate","by":0.5,"ttl":60}`)},
+			want:       `{"key":"rate","value":0.5}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not a number",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"name"}`)},
+			want:       `{"error": "value is not a number"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "store error",
+			args:       args{method: http.MethodPost, contentType: "application/json", body: bytes.NewBufferString(`{"key":"broken"}`)},
+			want:       `{"error": "connection refused"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.args.method, "/redis/incr", tt.args.body)
+			r.Header.Add("Content-Type", tt.args.contentType)
+			rw := httptest.NewRecorder()
+			NewIncrHandler(client).ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"getircase/databases"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// maxIngestDocuments upper bound of documents in a single ingest request
+const maxIngestDocuments = 1000
+
+// maxIngestBody upper bound of ingest request body size
+const maxIngestBody = 4 << 20
+
+type IngestResponse struct {
+	Code     int                             `json:"code"`
+	Msg      string                          `json:"msg"`
+	Inserted int                             `json:"inserted"`
+	Errors   []*databases.MongodbInsertError `json:"errors,omitempty"`
+}
+
+type mongodbIngestHandler struct {
+	client databases.MongoClient
+}
+
+func NewMongodbIngestHandler(c databases.MongoClient) *mongodbIngestHandler {
+	return &mongodbIngestHandler{
+		client: c,
+	}
+}
+
+func (h *mongodbIngestHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		createFailResponse(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
+		return
+	}
+	if r.Header.Get("content-type") != "application/json" {
+		createFailResponse(rw, http.StatusUnsupportedMediaType, ErrInvalidContentType)
+		return
+	}
+
+	h.Ingest(rw, r)
+}
+
+// Ingest accepts a document or an array of documents, invalid documents are
+// reported by their index and never written. Documents are inserted ordered
+// unless ordered=false query parameter given, ordered ingests stop at the
+// first invalid or failing document.
+func (h *mongodbIngestHandler) Ingest(rw http.ResponseWriter, r *http.Request) {
+	ordered := true
+	switch r.URL.Query().Get("ordered") {
+	case "", "true":
+	case "false":
+		ordered = false
+	default:
+		createFailResponse(rw, http.StatusBadRequest, ErrInvalidOrdered)
+		return
+	}
+
+	f, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxIngestBody))
+	if err != nil {
+		createFailResponse(rw, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	docs, err := decodeDocuments(f)
+	if err != nil {
+		createFailResponse(rw, http.StatusBadRequest, ErrInvalidInput)
+		return
+	}
+	if len(docs) == 0 {
+		createFailResponse(rw, http.StatusBadRequest, ErrInvalidInput)
+		return
+	}
+	if len(docs) > maxIngestDocuments {
+		createFailResponse(rw, http.StatusRequestEntityTooLarge, ErrTooManyDocuments)
+		return
+	}
+
+	var errs []*databases.MongodbInsertError
+	// positions of valid documents in request
+	var indexes []int
+	var valid []*databases.MongodbDocument
+	for idx, doc := range docs {
+		if err := validateDocument(doc); err != nil {
+			errs = append(errs, &databases.MongodbInsertError{Index: idx, Msg: err.Error()})
+			if ordered {
+				break
+			}
+			continue
+		}
+		indexes = append(indexes, idx)
+		valid = append(valid, doc)
+	}
+
+	result, err := h.client.Insert(r.Context(), valid, ordered)
+	if err != nil {
+		createFailResponse(rw, http.StatusInternalServerError, ErrInsertError)
+		return
+	}
+	for _, e := range result.Errors {
+		errs = append(errs, &databases.MongodbInsertError{Index: indexes[e.Index], Msg: e.Msg})
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Index < errs[j].Index
+	})
+	if ordered && len(errs) > 1 {
+		errs = errs[:1]
+	}
+
+	resp := &IngestResponse{Code: 0, Msg: "success", Inserted: result.Inserted, Errors: errs}
+	status := http.StatusOK
+	if len(errs) > 0 {
+		resp.Code, resp.Msg = 1, ErrPartialInsert.Error()
+		status = http.StatusMultiStatus
+	}
+	d, err := json.Marshal(resp)
+	if err != nil {
+		createFailResponse(rw, http.StatusInternalServerError, ErrMarshalError)
+		return
+	}
+	rw.WriteHeader(status)
+	rw.Write(d)
+}
+
+// decodeDocuments decodes a single document or an array of documents
+func decodeDocuments(b []byte) ([]*databases.MongodbDocument, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		var docs []*databases.MongodbDocument
+		if err := json.Unmarshal(b, &docs); err != nil {
+			return nil, err
+		}
+		return docs, nil
+	}
+	doc := &databases.MongodbDocument{}
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+	return []*databases.MongodbDocument{doc}, nil
+}
+
+// validateDocument documents without creation time are created now
+func validateDocument(doc *databases.MongodbDocument) error {
+	if doc == nil {
+		return ErrInvalidInput
+	}
+	if doc.Key == "" {
+		return ErrKeyEmpty
+	}
+	if doc.Count == nil {
+		return ErrCountMissing
+	}
+	if *doc.Count < 0 {
+		return ErrNegativeCount
+	}
+	if doc.CreatedAt == nil {
+		t := databases.Time(time.Now().UTC())
+		doc.CreatedAt = &t
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"getircase/databases"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_mongodbIngestHandler_ServeHTTP(t *testing.T) {
+	inserted := func(docs []*databases.MongodbDocument, ordered bool) (*databases.MongodbInsertResult, error) {
+		return &databases.MongodbInsertResult{Inserted: len(docs)}, nil
+	}
+	type args struct {
+		method      string
+		path        string
+		contentType string
+		body        io.Reader
+	}
+	tests := []struct {
+		name       string
+		insert     func([]*databases.MongodbDocument, bool) (*databases.MongodbInsertResult, error)
+		args       args
+		want       string
+		wantStatus int
+	}{
+		{
+			name:       "get",
+			insert:     inserted,
+			args:       args{method: http.MethodGet, path: "/mongodb/records/ingest", contentType: "application/json"},
+			want:       `{"code":1,"msg":"method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "wrong content type",
+			insert:     inserted,
+			args:       args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "text/html"},
+			want:       `{"code":1,"msg":"invalid content-type"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "invalid ordered",
+			insert: inserted,
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest?ordered=maybe", contentType: "application/json",
+				body: bytes.NewBufferString(`{"key":"a","count":1}`)},
+			want:       `{"code":1,"msg":"invalid ordered"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty body",
+			insert:     inserted,
+			args:       args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "application/json"},
+			want:       `{"code":1,"msg":"invalid json input"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "empty array",
+			insert: inserted,
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "application/json",
+				body: bytes.NewBufferString(`[]`)},
+			want:       `{"code":1,"msg":"invalid json input"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "single document",
+			insert: inserted,
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "application/json",
+				body: bytes.NewBufferString(`{"key":"a","count":1,"createdAt":"2023-01-02T10:00:00Z"}`)},
+			want:       `{"code":0,"msg":"success","inserted":1}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "batch",
+			insert: inserted,
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "application/json",
+				body: bytes.NewBufferString(` [{"key":"a","count":1},{"key":"b","count":2,"createdAt":"2023-01-02"}]`)},
+			want:       `{"code":0,"msg":"success","inserted":2}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "batch / ordered / invalid document stops",
+			insert: inserted,
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"a","count":1},{"key":"","count":2},{"key":"c"}]`)},
+			want:       `{"code":1,"msg":"some documents are not inserted","inserted":1,"errors":[{"index":1,"msg":"key can not be empty"}]}`,
+			wantStatus: http.StatusMultiStatus,
+		},
+		{
+			name: "batch / unordered / invalid and failed documents",
+			insert: func(docs []*databases.MongodbDocument, ordered bool) (*databases.MongodbInsertResult, error) {
+				// b is the second valid document
+				return &databases.MongodbInsertResult{Inserted: 1, Errors: []*databases.MongodbInsertError{
+					{Index: 1, Msg: "duplicate key"},
+				}}, nil
+			},
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest?ordered=false", contentType: "application/json",
+				body: bytes.NewBufferString(`[{"key":"a","count":-1},{"key":"b","count":1},{"key":"c"},{"key":"d","count":1}]`)},
+			want:       `{"code":1,"msg":"some documents are not inserted","inserted":1,"errors":[{"index":0,"msg":"count can not be negative"},{"index":2,"msg":"count can not be empty"},{"index":3,"msg":"duplicate key"}]}`,
+			wantStatus: http.StatusMultiStatus,
+		},
+		{
+			name: "insert error",
+			insert: func(docs []*databases.MongodbDocument, ordered bool) (*databases.MongodbInsertResult, error) {
+				return nil, errors.New("connection refused")
+			},
+			args: args{method: http.MethodPost, path: "/mongodb/records/ingest", contentType: "application/json",
+				body: bytes.NewBufferString(`{"key":"a","count":1}`)},
+			want:       `{"code":1,"msg":"mongodb: insert error"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.args.method, tt.args.path, tt.args.body)
+			r.Header.Add("Content-Type", tt.args.contentType)
+			rw := httptest.NewRecorder()
+			h := NewMongodbIngestHandler(&mockMongo{i: tt.insert})
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -21,7 +21,15 @@ func NewInmemoryHandler(client databases.Inmemory) *InmemoryHandler {
 
 func (h *InmemoryHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Add("Content-Type", "application/json")
-	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodPost, http.MethodGet:
+	case http.MethodDelete:
+		h.Delete(rw, r)
+		return
+	case http.MethodHead:
+		h.Exists(rw, r)
+		return
+	default:
 		writeError(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
 		return
 	}
@@ -59,26 +67,34 @@ func (h *InmemoryHandler) CreateOrUpdate(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := h.client.Set(command); err != nil {
-		writeError(rw, http.StatusBadRequest, err)
-		return
+	var err error
+	if cond := condition(r); cond != nil {
+		err = h.client.SetIf(command, cond)
+	} else {
+		err = h.client.Set(command)
 	}
-
-	cmd, err := h.client.Get(command)
 	if err != nil {
+		if err == databases.ErrPreconditionFailed {
+			writeError(rw, http.StatusPreconditionFailed, err)
+			return
+		}
 		writeError(rw, http.StatusBadRequest, err)
 		return
 	}
 
-	b, err := json.Marshal(cmd)
+	// written command is responded, a read after the write could see
+	// another client's value
+	b, err := json.Marshal(command)
 	if err != nil {
 		writeError(rw, http.StatusBadRequest, err)
 		return
 	}
 
+	rw.Header().Set("ETag", databases.ETag(command.Version))
 	rw.Write(b)
 }
 
+// Get responds not found when key does not exist
 func (h *InmemoryHandler) Get(rw http.ResponseWriter, r *http.Request) {
 	key := r.URL.Query().Get("key")
 	if key == "" {
@@ -91,7 +107,11 @@ func (h *InmemoryHandler) Get(rw http.ResponseWriter, r *http.Request) {
 
 	cmd, err := h.client.Get(command)
 	if err != nil {
-		writeError(rw, http.StatusBadRequest, err)
+		if databases.IsKeyNotFound(err) {
+			writeError(rw, http.StatusNotFound, err)
+			return
+		}
+		writeError(rw, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -101,5 +121,51 @@ func (h *InmemoryHandler) Get(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if cmd != nil {
+		rw.Header().Set("ETag", databases.ETag(cmd.Version))
+	}
 	rw.Write(b)
 }
+
+// Delete responds no content when key is deleted, not found when it does not exist
+func (h *InmemoryHandler) Delete(rw http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(rw, http.StatusBadRequest, ErrKeyEmpty)
+		return
+	}
+
+	deleted, err := h.client.Delete(&databases.InmemoryCommand{Key: key})
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	if !deleted {
+		writeError(rw, http.StatusNotFound, ErrKeyNotFound)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// Exists responds no content when key exists, not found otherwise, HEAD
+// responses have no body so errors are reported by status only
+func (h *InmemoryHandler) Exists(rw http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.client.Exists(&databases.InmemoryCommand{Key: key})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -15,8 +15,43 @@ import (
 )
 
 type mockInmemory struct {
-	g func(*databases.InmemoryCommand) (*databases.InmemoryCommand, error)
-	s func(*databases.InmemoryCommand) error
+	g  func(*databases.InmemoryCommand) (*databases.InmemoryCommand, error)
+	s  func(*databases.InmemoryCommand) error
+	d  func(*databases.InmemoryCommand) (bool, error)
+	e  func(*databases.InmemoryCommand) (bool, error)
+	k  func(*databases.KeysCommand) (*databases.KeysResult, error)
+	si func(*databases.InmemoryCommand, *databases.Condition) error
+	n  func(*databases.IncrCommand) (*databases.IncrResult, error)
+	gm func([]string) ([]*databases.BatchResult, error)
+	sm func([]*databases.InmemoryCommand) ([]*databases.BatchResult, error)
+}
+
+func (m *mockInmemory) SetIf(cmd *databases.InmemoryCommand, cond *databases.Condition) error {
+	return m.si(cmd, cond)
+}
+
+func (m *mockInmemory) Incr(cmd *databases.IncrCommand) (*databases.IncrResult, error) {
+	return m.n(cmd)
+}
+
+func (m *mockInmemory) GetMany(keys []string) ([]*databases.BatchResult, error) {
+	return m.gm(keys)
+}
+
+func (m *mockInmemory) SetMany(cmds []*databases.InmemoryCommand) ([]*databases.BatchResult, error) {
+	return m.sm(cmds)
+}
+
+func (m *mockInmemory) Keys(cmd *databases.KeysCommand) (*databases.KeysResult, error) {
+	return m.k(cmd)
+}
+
+func (m *mockInmemory) Delete(cmd *databases.InmemoryCommand) (bool, error) {
+	return m.d(cmd)
+}
+
+func (m *mockInmemory) Exists(cmd *databases.InmemoryCommand) (bool, error) {
+	return m.e(cmd)
 }
 
 func (m *mockInmemory) Get(cmd *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
@@ -37,10 +72,11 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 		body        io.Reader
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   string
+		name       string
+		fields     fields
+		args       args
+		want       string
+		wantStatus int
 	}{
 		{
 			name: "inmemory patch",
@@ -49,7 +85,8 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 				path:        "/inmemory",
 				contentType: "application/json",
 			},
-			want: `{"error": "method not allowed"}`,
+			want:       `{"error": "method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
 			fields: fields{client: &mockInmemory{
 				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
 					return nil, errors.New("redis: nil")
@@ -63,10 +100,26 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 				path:        "/inmemory?key=not-exists",
 				contentType: "application/json",
 			},
-			want: `{"error": "inmemory: nil"}`,
+			want:       `{"error": "inmemory: nil"}`,
+			wantStatus: http.StatusNotFound,
 			fields: fields{client: &mockInmemory{
 				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
-					return nil, errors.New("inmemory: nil")
+					return nil, databases.ErrInmemoryKeyNotFound
+				},
+			}},
+		},
+		{
+			name: "inmemory get / error",
+			args: args{
+				method:      http.MethodGet,
+				path:        "/inmemory?key=broken",
+				contentType: "application/json",
+			},
+			want:       `{"error": "connection refused"}`,
+			wantStatus: http.StatusInternalServerError,
+			fields: fields{client: &mockInmemory{
+				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
+					return nil, errors.New("connection refused")
 				},
 			}},
 		},
@@ -77,7 +130,8 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 				path:        "/inmemory?key=exists",
 				contentType: "application/json",
 			},
-			want: `{"key":"exists","value":"exists"}`,
+			want:       `{"key":"exists","value":"exists"}`,
+			wantStatus: http.StatusOK,
 			fields: fields{client: &mockInmemory{
 				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
 					return &databases.InmemoryCommand{Key: "exists", Value: "exists"}, nil
@@ -91,7 +145,8 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 				path:        "/inmemory",
 				contentType: "text/html",
 			},
-			want: `{"error": "invalid content-type"}`,
+			want:       `{"error": "invalid content-type"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
 			fields: fields{client: &mockInmemory{
 				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
 					return nil, nil
@@ -108,7 +163,8 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 				path:        "/inmemory",
 				contentType: "application/json",
 			},
-			want: `{"error": "invalid json input"}`,
+			want:       `{"error": "invalid json input"}`,
+			wantStatus: http.StatusBadRequest,
 			fields: fields{client: &mockInmemory{
 				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
 					return nil, nil
@@ -126,7 +182,8 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 				contentType: "application/json",
 				body:        bytes.NewBufferString(`{"key": "test","value":"test"}`),
 			},
-			want: `{"key":"test","value":"test"}`,
+			want:       `{"key":"test","value":"test"}`,
+			wantStatus: http.StatusOK,
 			fields: fields{client: &mockInmemory{
 				g: func(ic *databases.InmemoryCommand) (*databases.InmemoryCommand, error) {
 					return ic, nil
@@ -148,6 +205,86 @@ func TestInmemoryHandler_ServeHTTP(t *testing.T) {
 			if rw.Body.String() != tt.want {
 				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
 			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
 		})
 	}
 }
+
+func TestInmemoryHandler_DeleteExists(t *testing.T) {
+	keys := map[string]bool{"exists": true}
+	client := &mockInmemory{
+		d: func(cmd *databases.InmemoryCommand) (bool, error) {
+			if cmd.Key == "broken" {
+				return false, errors.New("broken")
+			}
+			return keys[cmd.Key], nil
+		},
+		e: func(cmd *databases.InmemoryCommand) (bool, error) {
+			if cmd.Key == "broken" {
+				return false, errors.New("broken")
+			}
+			return keys[cmd.Key], nil
+		},
+	}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		want       string
+		wantStatus int
+	}{
+		{name: "delete / exists", method: http.MethodDelete, path: "/inmemory?key=exists", want: "", wantStatus: http.StatusNoContent},
+		{name: "delete / not exists", method: http.MethodDelete, path: "/inmemory?key=not-exists", want: `{"error": "key not found"}`, wantStatus: http.StatusNotFound},
+		{name: "delete / empty key", method: http.MethodDelete, path: "/inmemory", want: `{"error": "key can not be empty"}`, wantStatus: http.StatusBadRequest},
+		{name: "delete / error", method: http.MethodDelete, path: "/inmemory?key=broken", want: `{"error": "broken"}`, wantStatus: http.StatusInternalServerError},
+		{name: "head / exists", method: http.MethodHead, path: "/inmemory?key=exists", want: "", wantStatus: http.StatusNoContent},
+		{name: "head / not exists", method: http.MethodHead, path: "/inmemory?key=not-exists", want: "", wantStatus: http.StatusNotFound},
+		{name: "head / empty key", method: http.MethodHead, path: "/inmemory", want: "", wantStatus: http.StatusBadRequest},
+		{name: "head / error", method: http.MethodHead, path: "/inmemory?key=broken", want: "", wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			rw := httptest.NewRecorder()
+			h := NewInmemoryHandler(client)
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestInmemoryHandler_Conditional(t *testing.T) {
+	client := &mockInmemory{
+		si: func(cmd *databases.InmemoryCommand, cond *databases.Condition) error {
+			if len(cond.IfNoneMatch) == 1 && cond.IfNoneMatch[0] == "*" {
+				return databases.ErrPreconditionFailed
+			}
+			cmd.Version = 2
+			return nil
+		},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/inmemory", bytes.NewBufferString(`{"key":"k","value":"v"}`))
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("If-None-Match", "*")
+	rw := httptest.NewRecorder()
+	NewInmemoryHandler(client).ServeHTTP(rw, r)
+	if rw.Code != http.StatusPreconditionFailed || rw.Body.String() != `{"error": "precondition failed"}` {
+		t.Errorf("ServeHTTP() = %d %s, want precondition failed", rw.Code, rw.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/inmemory", bytes.NewBufferString(`{"key":"k","value":"v"}`))
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("If-Match", databases.ETag(1))
+	rw = httptest.NewRecorder()
+	NewInmemoryHandler(client).ServeHTTP(rw, r)
+	if rw.Code != http.StatusOK || rw.Header().Get("ETag") != databases.ETag(2) {
+		t.Errorf("ServeHTTP() = %d %s, ETag %s", rw.Code, rw.Body.String(), rw.Header().Get("ETag"))
+	}
+}
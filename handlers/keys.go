@@ -0,0 +1,72 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"encoding/json"
+	"getircase/databases"
+	"net/http"
+	"strconv"
+)
+
+// keysLister stores which keys can be listed, redis and inmemory
+type keysLister interface {
+	Keys(*databases.KeysCommand) (*databases.KeysResult, error)
+}
+
+type KeysHandler struct {
+	client keysLister
+}
+
+func NewKeysHandler(client keysLister) *KeysHandler {
+	return &KeysHandler{client: client}
+}
+
+func (h *KeysHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
+		return
+	}
+
+	h.Keys(rw, r)
+}
+
+// Keys lists keys matching glob pattern of match parameter, page size is
+// given by count and next page is requested with cursor of the response
+func (h *KeysHandler) Keys(rw http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cmd := &databases.KeysCommand{
+		Match:  q.Get("match"),
+		Cursor: q.Get("cursor"),
+	}
+	if value := q.Get("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 1 || count > databases.MaxKeysCount {
+			writeError(rw, http.StatusBadRequest, ErrInvalidKeysCount)
+			return
+		}
+		cmd.Count = count
+	}
+
+	result, err := h.client.Keys(cmd)
+	if err != nil {
+		if err == databases.ErrInvalidKeysCursor {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Write(b)
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package handlers
+
+import (
+	"errors"
+	"getircase/databases"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestKeysHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		keys       func(*databases.KeysCommand) (*databases.KeysResult, error)
+		want       string
+		wantStatus int
+		wantCmd    *databases.KeysCommand
+	}{
+		{
+			name:       "post",
+			method:     http.MethodPost,
+			path:       "/redis/keys",
+			want:       `{"error": "method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "invalid count",
+			method:     http.MethodGet,
+			path:       "/redis/keys?count=0",
+			want:       `{"error": "invalid count"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid cursor",
+			method: http.MethodGet,
+			path:   "/redis/keys?cursor=x",
+			keys: func(cmd *databases.KeysCommand) (*databases.KeysResult, error) {
+				return nil, databases.ErrInvalidKeysCursor
+			},
+			want:       `{"error": "invalid keys cursor"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCmd:    &databases.KeysCommand{Cursor: "x"},
+		},
+		{
+			name:   "store error",
+			method: http.MethodGet,
+			path:   "/redis/keys",
+			keys: func(cmd *databases.KeysCommand) (*databases.KeysResult, error) {
+				return nil, errors.New("down")
+			},
+			want:       `{"error": "down"}`,
+			wantStatus: http.StatusInternalServerError,
+			wantCmd:    &databases.KeysCommand{},
+		},
+		{
+			name:   "page",
+			method: http.MethodGet,
+			path:   "/redis/keys?match=user:*&cursor=12&count=2",
+			keys: func(cmd *databases.KeysCommand) (*databases.KeysResult, error) {
+				return &databases.KeysResult{Keys: []string{"user:1", "user:2"}, Cursor: "20"}, nil
+			},
+			want:       `{"keys":["user:1","user:2"],"cursor":"20"}`,
+			wantStatus: http.StatusOK,
+			wantCmd:    &databases.KeysCommand{Match: "user:*", Cursor: "12", Count: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCmd *databases.KeysCommand
+			h := NewKeysHandler(&mockRedis{k: func(cmd *databases.KeysCommand) (*databases.KeysResult, error) {
+				gotCmd = cmd
+				return tt.keys(cmd)
+			}})
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(gotCmd, tt.wantCmd) {
+				t.Errorf("ServeHTTP() command = %+v, want %+v", gotCmd, tt.wantCmd)
+			}
+		})
+	}
+}
@@ -5,16 +5,36 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"getircase/databases"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// maxLimit upper bound of page size clients can request
+const maxLimit = 1000
+
+// maxKeys upper bound of keys which can be filtered by exact list
+const maxKeys = 1000
+
+// timeoutHeader request header which limits the time spent on a query, in milliseconds
+const timeoutHeader = "X-Request-Timeout"
+
+// maxRequestTimeout upper bound of the time clients can ask a query to run
+const maxRequestTimeout = 5 * time.Minute
+
 type Response struct {
-	Code    int                        `json:"code"`
-	Msg     string                     `json:"msg"`
-	Records []*databases.MongodbRecord `json:"records,omitempty"`
+	Code       int                        `json:"code"`
+	Msg        string                     `json:"msg"`
+	Records    []*databases.MongodbRecord `json:"records,omitempty"`
+	NextCursor string                     `json:"nextCursor,omitempty"`
+	Count      *int                       `json:"count,omitempty"`
+	Summary    *databases.MongodbSummary  `json:"summary,omitempty"`
 }
 
 type mongodbHandler struct {
@@ -42,40 +62,193 @@ func (h *mongodbHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 }
 
 func (h *mongodbHandler) Retrieve(rw http.ResponseWriter, r *http.Request) {
-	var filter = &databases.MongodbFilter{}
-	if r.ContentLength != 0 {
-		f, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			createFailResponse(rw, http.StatusInternalServerError, err)
-			return
-		}
+	filter, status, err := decodeFilter(r)
+	if err != nil {
+		createFailResponse(rw, status, err)
+		return
+	}
 
-		if err := json.Unmarshal(f, filter); err != nil {
-			createFailResponse(rw, http.StatusInternalServerError, ErrMarshalError)
+	ctx, cancel, err := requestContext(r)
+	if err != nil {
+		createFailResponse(rw, http.StatusBadRequest, err)
+		return
+	}
+	defer cancel()
+	if noCache(r) {
+		ctx = databases.WithoutCache(ctx)
+	}
+
+	// totals are a single value, there is nothing to stream
+	if mediaType := negotiate(r.Header.Get("Accept")); mediaType != mimeJSON && !filter.IsTotals() {
+		// exports have no next cursor, a limited one would look complete
+		if filter.Limit != nil || filter.Cursor != "" {
+			createFailResponse(rw, http.StatusBadRequest, ErrStreamNotPaginated)
 			return
 		}
+		h.stream(ctx, rw, filter, mediaType)
+		return
 	}
 
-	records, err := h.client.Fetch(filter)
+	result, err := h.client.Fetch(ctx, filter)
 	if err != nil {
-		createFailResponse(rw, http.StatusInternalServerError, ErrFetchError)
+		createFetchFailResponse(rw, ctx, err)
 		return
 	}
-	resp := createSuccessResponse(records)
+	resp := createSuccessResponse(result)
 	d, err := json.Marshal(resp)
 	if err != nil {
 		createFailResponse(rw, http.StatusInternalServerError, ErrMarshalError)
 		return
 	}
+	if result.Cache != "" {
+		rw.Header().Set("X-Cache", result.Cache)
+	}
 	rw.WriteHeader(http.StatusOK)
 	rw.Write(d)
 }
 
-func createSuccessResponse(records []*databases.MongodbRecord) *Response {
+// decodeFilter reads and validates filter in request body, status code of
+// the failure returned with error
+func decodeFilter(r *http.Request) (*databases.MongodbFilter, int, error) {
+	var filter = &databases.MongodbFilter{}
+	if r.ContentLength != 0 {
+		f, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		if err := json.Unmarshal(f, filter); err != nil {
+			return nil, http.StatusInternalServerError, ErrMarshalError
+		}
+	}
+
+	if err := validateFilter(filter); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return filter, http.StatusOK, nil
+}
+
+// requestContext context of the query, cancelled when client goes away or
+// deadline given by X-Request-Timeout header exceeded
+func requestContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	value := r.Header.Get(timeoutHeader)
+	if value == "" {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, nil
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	// compared in milliseconds, huge values overflow when converted to duration
+	if err != nil || ms < 1 || ms > maxRequestTimeout.Milliseconds() {
+		return nil, nil, ErrInvalidTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(ms)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+// noCache reports whether client asks for a fresh result
+func noCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store":
+			return true
+		}
+	}
+	return false
+}
+
+// createFetchFailResponse queries cancelled by deadlines are reported as
+// gateway timeout, nothing is written if client already gone
+func createFetchFailResponse(rw http.ResponseWriter, ctx context.Context, err error) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if databases.IsTimeout(err) {
+		createFailResponse(rw, http.StatusGatewayTimeout, ErrFetchTimeout)
+		return
+	}
+	createFailResponse(rw, http.StatusInternalServerError, ErrFetchError)
+}
+
+func validateFilter(filter *databases.MongodbFilter) error {
+	if filter.Limit != nil && (*filter.Limit < 1 || *filter.Limit > maxLimit) {
+		return ErrInvalidLimit
+	}
+	switch filter.SortBy {
+	case "", databases.SortByKey, databases.SortByTotalCount, databases.SortByCreatedAt:
+	default:
+		return ErrInvalidSortBy
+	}
+	switch filter.Order {
+	case "", databases.OrderAsc, databases.OrderDesc:
+	default:
+		return ErrInvalidOrder
+	}
+	switch filter.Bucket {
+	case "", databases.BucketHour, databases.BucketDay, databases.BucketWeek, databases.BucketMonth:
+	default:
+		return ErrInvalidBucket
+	}
+	switch filter.Mode {
+	case "", databases.ModeRecords:
+	case databases.ModeCount, databases.ModeSummary:
+		if filter.Limit != nil || filter.Cursor != "" {
+			return ErrTotalsNotPaginated
+		}
+	default:
+		return ErrInvalidMode
+	}
+	if filter.MinTotalCount != nil && filter.MaxTotalCount != nil && *filter.MinTotalCount > *filter.MaxTotalCount {
+		return ErrInvalidTotalCount
+	}
+	if len(filter.Keys) > maxKeys {
+		return ErrTooManyKeys
+	}
+	if filter.KeyPattern != "" {
+		if err := databases.ValidateKeyPattern(filter.KeyPattern); err != nil {
+			return err
+		}
+	}
+	if err := validateMetrics(filter.Metrics); err != nil {
+		return err
+	}
+	if filter.Cursor != "" {
+		cursor, err := databases.DecodeCursor(filter.Cursor)
+		if err != nil {
+			return err
+		}
+		if !cursor.Matches(filter) {
+			return databases.ErrCursorMismatch
+		}
+	}
+	return nil
+}
+
+// validateMetrics every metric must be known and requested once
+func validateMetrics(metrics []string) error {
+	seen := make(map[string]bool, len(metrics))
+	for _, name := range metrics {
+		if seen[name] {
+			return ErrInvalidMetric
+		}
+		seen[name] = true
+	}
+	for _, name := range databases.Metrics {
+		delete(seen, name)
+	}
+	if len(seen) > 0 {
+		return ErrInvalidMetric
+	}
+	return nil
+}
+
+func createSuccessResponse(result *databases.MongodbResult) *Response {
 	return &Response{
-		Code:    0,
-		Msg:     "success",
-		Records: records,
+		Code:       0,
+		Msg:        "success",
+		Records:    result.Records,
+		NextCursor: result.NextCursor,
+		Count:      result.Count,
+		Summary:    result.Summary,
 	}
 }
 
@@ -6,6 +6,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"getircase/databases"
 	"io"
 	"net/http"
@@ -14,13 +15,33 @@ import (
 )
 
 type mockMongo struct {
-	f func(*databases.MongodbFilter) ([]*databases.MongodbRecord, error)
+	f  func(*databases.MongodbFilter) (*databases.MongodbResult, error)
+	st func(*databases.MongodbFilter, func(*databases.MongodbRecord) error) error
+	i  func([]*databases.MongodbDocument, bool) (*databases.MongodbInsertResult, error)
+	e  func(*databases.MongodbFilter) (*databases.MongodbExplain, error)
+	w  func(*databases.MongodbFilter, string) (databases.MongodbEventStream, error)
 }
 
-func (m *mockMongo) Fetch(f *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
+func (m *mockMongo) Watch(ctx context.Context, f *databases.MongodbFilter, resumeAfter string) (databases.MongodbEventStream, error) {
+	return m.w(f, resumeAfter)
+}
+
+func (m *mockMongo) Fetch(ctx context.Context, f *databases.MongodbFilter) (*databases.MongodbResult, error) {
 	return m.f(f)
 }
 
+func (m *mockMongo) Explain(ctx context.Context, f *databases.MongodbFilter) (*databases.MongodbExplain, error) {
+	return m.e(f)
+}
+
+func (m *mockMongo) Insert(ctx context.Context, docs []*databases.MongodbDocument, ordered bool) (*databases.MongodbInsertResult, error) {
+	return m.i(docs, ordered)
+}
+
+func (m *mockMongo) Stream(ctx context.Context, f *databases.MongodbFilter, fn func(*databases.MongodbRecord) error) error {
+	return m.st(f, fn)
+}
+
 func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 	type fields struct {
 		client databases.MongoClient
@@ -30,6 +51,7 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 		path        string
 		contentType string
 		body        io.Reader
+		timeout     string
 	}
 	tests := []struct {
 		name   string
@@ -46,7 +68,7 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":1,"msg":"method not allowed"}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
 					return nil, nil
 				},
 			}},
@@ -60,7 +82,7 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":1,"msg":"invalid content-type"}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
 					return nil, nil
 				},
 			}},
@@ -74,14 +96,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":0,"msg":"success","records":[{"key":"a","createdAt":"","totalCount":1}]}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -95,14 +117,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":1,"msg":"json: marshal"}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -116,14 +138,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":1,"msg":"json: marshal"}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -137,14 +159,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":0,"msg":"success","records":[{"key":"a","createdAt":"","totalCount":1}]}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -158,14 +180,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":1,"msg":"json: marshal"}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -179,14 +201,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":1,"msg":"json: marshal"}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -200,14 +222,14 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":0,"msg":"success","records":[{"key":"a","createdAt":"","totalCount":1}]}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
-					}, nil
+					}}, nil
 				},
 			}},
 		},
@@ -221,22 +243,337 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 			},
 			want: `{"code":0,"msg":"success","records":[{"key":"a","createdAt":"","totalCount":1}]}`,
 			fields: fields{client: &mockMongo{
-				f: func(ic *databases.MongodbFilter) ([]*databases.MongodbRecord, error) {
-					return []*databases.MongodbRecord{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
 						&databases.MongodbRecord{
 							Key:        "a",
 							CreatedAt:  "",
 							TotalCount: 1,
 						},
+					}}, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid limit",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"limit":0}`),
+			},
+			want: `{"code":1,"msg":"invalid limit"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid cursor",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"cursor":"!!"}`),
+			},
+			want: `{"code":1,"msg":"invalid cursor"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid sortBy",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"sortBy":"value"}`),
+			},
+			want: `{"code":1,"msg":"invalid sortBy"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid order",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"sortBy":"totalCount","order":"up"}`),
+			},
+			want: `{"code":1,"msg":"invalid order"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid bucket",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"bucket":"year"}`),
+			},
+			want: `{"code":1,"msg":"invalid bucket"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid metric",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"metrics":["sum","median"]}`),
+			},
+			want: `{"code":1,"msg":"invalid metric"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / duplicate metric",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"metrics":["sum","sum"]}`),
+			},
+			want: `{"code":1,"msg":"invalid metric"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / minTotalCount greater than maxTotalCount",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"minTotalCount":10,"maxTotalCount":5}`),
+			},
+			want: `{"code":1,"msg":"minTotalCount can not be greater than maxTotalCount"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / minTotalCount",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"minTotalCount":1}`),
+			},
+			want: `{"code":0,"msg":"success","records":[{"key":"a","createdAt":"","totalCount":1}]}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Records: []*databases.MongodbRecord{
+						{Key: "a", TotalCount: 1},
+					}}, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid keyPattern",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"keyPattern":"(a"}`),
+			},
+			want: `{"code":1,"msg":"invalid keyPattern"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / complex keyPattern",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"keyPattern":"(a+)+b"}`),
+			},
+			want: `{"code":1,"msg":"keyPattern is too complex"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / cursor of another sort",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"sortBy":"totalCount","cursor":"` + databases.EncodeCursor(&databases.Cursor{ID: "a"}) + `"}`),
+			},
+			want: `{"code":1,"msg":"cursor does not match sort"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / limit / next cursor",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"limit":1}`),
+			},
+			want: `{"code":0,"msg":"success","records":[{"key":"a","createdAt":"","totalCount":1}],"nextCursor":"next"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{
+						Records: []*databases.MongodbRecord{
+							&databases.MongodbRecord{
+								Key:        "a",
+								CreatedAt:  "",
+								TotalCount: 1,
+							},
+						},
+						NextCursor: "next",
 					}, nil
 				},
 			}},
 		},
+		{
+			name: "mongo post / invalid mode",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"mode":"groups"}`),
+			},
+			want: `{"code":1,"msg":"invalid mode"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / count with limit",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"mode":"count","limit":10}`),
+			},
+			want: `{"code":1,"msg":"limit and cursor can not be used with count or summary mode"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / count",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"mode":"count"}`),
+			},
+			want: `{"code":0,"msg":"success","count":0}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					count := 0
+					return &databases.MongodbResult{Count: &count}, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / summary",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"mode":"summary"}`),
+			},
+			want: `{"code":0,"msg":"success","summary":{"keys":2,"totalCount":30,"docCount":5}}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Summary: &databases.MongodbSummary{Keys: 2, TotalCount: 30, DocCount: 5}}, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / invalid timeout",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				timeout:     "10s",
+			},
+			want: `{"code":1,"msg":"invalid X-Request-Timeout"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / overflowing timeout",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				timeout:     "10000000000000",
+			},
+			want: `{"code":1,"msg":"invalid X-Request-Timeout"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, nil
+				},
+			}},
+		},
+		{
+			name: "mongo post / timeout exceeded",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/mongodb/records",
+				contentType: "application/json",
+				timeout:     "100",
+			},
+			want: `{"code":1,"msg":"mongodb: fetch timeout"}`,
+			fields: fields{client: &mockMongo{
+				f: func(ic *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return nil, context.DeadlineExceeded
+				},
+			}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := httptest.NewRequest(tt.args.method, tt.args.path, tt.args.body)
 			r.Header.Add("Content-Type", tt.args.contentType)
+			if tt.args.timeout != "" {
+				r.Header.Add(timeoutHeader, tt.args.timeout)
+			}
 			rw := httptest.NewRecorder()
 			h := NewMongodbHandler(tt.fields.client)
 			h.ServeHTTP(rw, r)
@@ -247,3 +584,39 @@ func Test_mongodbHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func Test_mongodbHandler_cache(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		cache        string
+		wantHeader   string
+		wantBypass   bool
+	}{
+		{name: "not cached", cache: "", wantHeader: ""},
+		{name: "hit", cache: databases.CacheHit, wantHeader: "HIT"},
+		{name: "no-cache", cacheControl: "max-age=0, no-cache", cache: databases.CacheMiss, wantHeader: "MISS", wantBypass: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/mongodb/records", nil)
+			r.Header.Add("Content-Type", "application/json")
+			if tt.cacheControl != "" {
+				r.Header.Add("Cache-Control", tt.cacheControl)
+			}
+			if got := noCache(r); got != tt.wantBypass {
+				t.Errorf("noCache() = %v, want %v", got, tt.wantBypass)
+			}
+			rw := httptest.NewRecorder()
+			h := NewMongodbHandler(&mockMongo{
+				f: func(f *databases.MongodbFilter) (*databases.MongodbResult, error) {
+					return &databases.MongodbResult{Cache: tt.cache}, nil
+				},
+			})
+			h.ServeHTTP(rw, r)
+			if got := rw.Header().Get("X-Cache"); got != tt.wantHeader {
+				t.Errorf("ServeHTTP() X-Cache = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
@@ -24,7 +24,15 @@ func NewRedisHandler(client databases.Redis) *RedisHandler {
 func (h *RedisHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Add("Content-Type", "application/json")
 
-	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodPost, http.MethodGet:
+	case http.MethodDelete:
+		h.Delete(rw, r)
+		return
+	case http.MethodHead:
+		h.Exists(rw, r)
+		return
+	default:
 		writeError(rw, http.StatusMethodNotAllowed, ErrInvalidRequestMethod)
 		return
 	}
@@ -43,6 +51,7 @@ func (h *RedisHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 }
 
 func writeError(rw http.ResponseWriter, status int, err error) {
+	rw.WriteHeader(status)
 	rw.Write([]byte(fmt.Sprintf("{\"error\": \"%s\"}", err.Error())))
 }
 
@@ -64,26 +73,34 @@ func (h *RedisHandler) CreateOrUpdate(rw http.ResponseWriter, r *http.Request) {
 		writeError(rw, http.StatusBadRequest, ErrInvalidInput)
 		return
 	}
-	if err := h.client.Set(command); err != nil {
-		writeError(rw, http.StatusBadRequest, err)
-		return
+	var err error
+	if cond := condition(r); cond != nil {
+		err = h.client.SetIf(command, cond)
+	} else {
+		err = h.client.Set(command)
 	}
-
-	cmd, err := h.client.Get(command)
 	if err != nil {
+		if err == databases.ErrPreconditionFailed {
+			writeError(rw, http.StatusPreconditionFailed, err)
+			return
+		}
 		writeError(rw, http.StatusBadRequest, err)
 		return
 	}
 
-	b, err := json.Marshal(cmd)
+	// written command is responded, a read after the write could see
+	// another client's value
+	b, err := json.Marshal(command)
 	if err != nil {
 		writeError(rw, http.StatusBadRequest, err)
 		return
 	}
 
+	rw.Header().Set("ETag", databases.ETag(command.Version))
 	rw.Write(b)
 }
 
+// Get responds not found when key does not exist
 func (h *RedisHandler) Get(rw http.ResponseWriter, r *http.Request) {
 	key := r.URL.Query().Get("key")
 	if key == "" {
@@ -96,7 +113,11 @@ func (h *RedisHandler) Get(rw http.ResponseWriter, r *http.Request) {
 
 	cmd, err := h.client.Get(command)
 	if err != nil {
-		writeError(rw, http.StatusBadRequest, err)
+		if databases.IsKeyNotFound(err) {
+			writeError(rw, http.StatusNotFound, err)
+			return
+		}
+		writeError(rw, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -106,5 +127,55 @@ func (h *RedisHandler) Get(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if cmd != nil {
+		rw.Header().Set("ETag", databases.ETag(cmd.Version))
+	}
 	rw.Write(b)
 }
+
+// Delete responds no content when key is deleted, not found when it does not exist
+func (h *RedisHandler) Delete(rw http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(rw, http.StatusBadRequest, ErrKeyEmpty)
+		return
+	}
+
+	deleted, err := h.client.Delete(&databases.RedisCommand{Key: key})
+	if err != nil {
+		if err == databases.ErrReservedKey {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	if !deleted {
+		writeError(rw, http.StatusNotFound, ErrKeyNotFound)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// Exists responds no content when key exists, not found otherwise, HEAD
+// responses have no body so errors are reported by status only
+func (h *RedisHandler) Exists(rw http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.client.Exists(&databases.RedisCommand{Key: key})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
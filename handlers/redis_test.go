@@ -16,8 +16,43 @@ import (
 )
 
 type mockRedis struct {
-	g func(*databases.RedisCommand) (*databases.RedisCommand, error)
-	s func(*databases.RedisCommand) error
+	g  func(*databases.RedisCommand) (*databases.RedisCommand, error)
+	s  func(*databases.RedisCommand) error
+	d  func(*databases.RedisCommand) (bool, error)
+	e  func(*databases.RedisCommand) (bool, error)
+	k  func(*databases.KeysCommand) (*databases.KeysResult, error)
+	si func(*databases.RedisCommand, *databases.Condition) error
+	n  func(*databases.IncrCommand) (*databases.IncrResult, error)
+	gm func([]string) ([]*databases.BatchResult, error)
+	sm func([]*databases.RedisCommand) ([]*databases.BatchResult, error)
+}
+
+func (m *mockRedis) SetIf(cmd *databases.RedisCommand, cond *databases.Condition) error {
+	return m.si(cmd, cond)
+}
+
+func (m *mockRedis) Incr(cmd *databases.IncrCommand) (*databases.IncrResult, error) {
+	return m.n(cmd)
+}
+
+func (m *mockRedis) GetMany(keys []string) ([]*databases.BatchResult, error) {
+	return m.gm(keys)
+}
+
+func (m *mockRedis) SetMany(cmds []*databases.RedisCommand) ([]*databases.BatchResult, error) {
+	return m.sm(cmds)
+}
+
+func (m *mockRedis) Keys(cmd *databases.KeysCommand) (*databases.KeysResult, error) {
+	return m.k(cmd)
+}
+
+func (m *mockRedis) Delete(cmd *databases.RedisCommand) (bool, error) {
+	return m.d(cmd)
+}
+
+func (m *mockRedis) Exists(cmd *databases.RedisCommand) (bool, error) {
+	return m.e(cmd)
 }
 
 func (m *mockRedis) Get(cmd *databases.RedisCommand) (*databases.RedisCommand, error) {
@@ -37,10 +72,11 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 		body        io.Reader
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   string
+		name       string
+		fields     fields
+		args       args
+		want       string
+		wantStatus int
 	}{
 		{
 			name: "redis patch",
@@ -49,7 +85,8 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				path:        "/inmemory",
 				contentType: "application/json",
 			},
-			want: `{"error": "method not allowed"}`,
+			want:       `{"error": "method not allowed"}`,
+			wantStatus: http.StatusMethodNotAllowed,
 			fields: fields{client: &mockRedis{
 				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
 					return nil, errors.New("redis: nil")
@@ -63,10 +100,26 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				path:        "/redis?key=not-exists",
 				contentType: "application/json",
 			},
-			want: `{"error": "redis: nil"}`,
+			want:       `{"error": "redis: nil"}`,
+			wantStatus: http.StatusNotFound,
 			fields: fields{client: &mockRedis{
 				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
-					return nil, errors.New("redis: nil")
+					return nil, databases.ErrRedisKeyNotFound
+				},
+			}},
+		},
+		{
+			name: "redis get / error",
+			args: args{
+				method:      http.MethodGet,
+				path:        "/redis?key=broken",
+				contentType: "application/json",
+			},
+			want:       `{"error": "connection refused"}`,
+			wantStatus: http.StatusInternalServerError,
+			fields: fields{client: &mockRedis{
+				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
+					return nil, errors.New("connection refused")
 				},
 			}},
 		},
@@ -77,7 +130,8 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				path:        "/redis?key=exists",
 				contentType: "application/json",
 			},
-			want: `{"key":"exists","value":"exists"}`,
+			want:       `{"key":"exists","value":"exists"}`,
+			wantStatus: http.StatusOK,
 			fields: fields{client: &mockRedis{
 				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
 					return &databases.RedisCommand{Key: "exists", Value: "exists"}, nil
@@ -91,7 +145,8 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				path:        "/redis",
 				contentType: "application/json",
 			},
-			want: `{"error": "invalid json input"}`,
+			want:       `{"error": "invalid json input"}`,
+			wantStatus: http.StatusBadRequest,
 			fields: fields{client: &mockRedis{
 				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
 					return nil, nil
@@ -109,7 +164,8 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				path:        "/redis",
 				contentType: "text/html",
 			},
-			want: `{"error": "invalid content-type"}`,
+			want:       `{"error": "invalid content-type"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
 			fields: fields{client: &mockRedis{
 				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
 					return nil, nil
@@ -119,6 +175,22 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name: "redis post / reserved key",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/redis",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"key": "__version","value":"x"}`),
+			},
+			want:       `{"error": "redis: key is reserved"}`,
+			wantStatus: http.StatusBadRequest,
+			fields: fields{client: &mockRedis{
+				s: func(ic *databases.RedisCommand) error {
+					return databases.ErrReservedKey
+				},
+			}},
+		},
 		{
 			name: "redis post / valid body",
 			args: args{
@@ -127,7 +199,46 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 				contentType: "application/json",
 				body:        bytes.NewBufferString(`{"key": "test","value":"test"}`),
 			},
-			want: `{"key":"test","value":"test"}`,
+			want:       `{"key":"test","value":"test"}`,
+			wantStatus: http.StatusOK,
+			fields: fields{client: &mockRedis{
+				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
+					return ic, nil
+				},
+				s: func(ic *databases.RedisCommand) error {
+					return nil
+				},
+			}},
+		},
+		{
+			name: "redis post / ttl",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/redis",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"key": "test","value":"test","ttl":"1m"}`),
+			},
+			want:       `{"key":"test","value":"test","ttl":60}`,
+			wantStatus: http.StatusOK,
+			fields: fields{client: &mockRedis{
+				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
+					return ic, nil
+				},
+				s: func(ic *databases.RedisCommand) error {
+					return nil
+				},
+			}},
+		},
+		{
+			name: "redis post / invalid ttl",
+			args: args{
+				method:      http.MethodPost,
+				path:        "/redis",
+				contentType: "application/json",
+				body:        bytes.NewBufferString(`{"key": "test","value":"test","ttl":"soon"}`),
+			},
+			want:       `{"error": "redis: invalid ttl"}`,
+			wantStatus: http.StatusBadRequest,
 			fields: fields{client: &mockRedis{
 				g: func(ic *databases.RedisCommand) (*databases.RedisCommand, error) {
 					return ic, nil
@@ -149,6 +260,125 @@ func TestRedisHandler_ServeHTTP(t *testing.T) {
 			if rw.Body.String() != tt.want {
 				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
 			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRedisHandler_DeleteExists(t *testing.T) {
+	keys := map[string]bool{"exists": true}
+	client := &mockRedis{
+		d: func(cmd *databases.RedisCommand) (bool, error) {
+			if cmd.Key == "broken" {
+				return false, errors.New("broken")
+			}
+			if cmd.Key == "__version" {
+				return false, databases.ErrReservedKey
+			}
+			return keys[cmd.Key], nil
+		},
+		e: func(cmd *databases.RedisCommand) (bool, error) {
+			if cmd.Key == "broken" {
+				return false, errors.New("broken")
+			}
+			return keys[cmd.Key], nil
+		},
+	}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		want       string
+		wantStatus int
+	}{
+		{name: "delete / exists", method: http.MethodDelete, path: "/redis?key=exists", want: "", wantStatus: http.StatusNoContent},
+		{name: "delete / not exists", method: http.MethodDelete, path: "/redis?key=not-exists", want: `{"error": "key not found"}`, wantStatus: http.StatusNotFound},
+		{name: "delete / empty key", method: http.MethodDelete, path: "/redis", want: `{"error": "key can not be empty"}`, wantStatus: http.StatusBadRequest},
+		{name: "delete / error", method: http.MethodDelete, path: "/redis?key=broken", want: `{"error": "broken"}`, wantStatus: http.StatusInternalServerError},
+		{name: "delete / reserved key", method: http.MethodDelete, path: "/redis?key=__version", want: `{"error": "redis: key is reserved"}`, wantStatus: http.StatusBadRequest},
+		{name: "head / exists", method: http.MethodHead, path: "/redis?key=exists", want: "", wantStatus: http.StatusNoContent},
+		{name: "head / not exists", method: http.MethodHead, path: "/redis?key=not-exists", want: "", wantStatus: http.StatusNotFound},
+		{name: "head / empty key", method: http.MethodHead, path: "/redis", want: "", wantStatus: http.StatusBadRequest},
+		{name: "head / error", method: http.MethodHead, path: "/redis?key=broken", want: "", wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			rw := httptest.NewRecorder()
+			h := NewRedisHandler(client)
+			h.ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRedisHandler_Conditional(t *testing.T) {
+	stored, version := "v1", uint64(1)
+	client := &mockRedis{
+		g: func(cmd *databases.RedisCommand) (*databases.RedisCommand, error) {
+			return &databases.RedisCommand{Key: cmd.Key, Value: stored, Version: version}, nil
+		},
+		s: func(cmd *databases.RedisCommand) error {
+			stored, version = cmd.Value, version+1
+			cmd.Version = version
+			return nil
+		},
+		si: func(cmd *databases.RedisCommand, cond *databases.Condition) error {
+			for _, tag := range cond.IfMatch {
+				if tag == databases.ETag(version) {
+					stored, version = cmd.Value, version+1
+					cmd.Version = version
+					return nil
+				}
+			}
+			return databases.ErrPreconditionFailed
+		},
+	}
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		tags       string
+		body       string
+		want       string
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "get", method: http.MethodGet, want: `{"key":"k","value":"v1"}`, wantStatus: http.StatusOK, wantETag: databases.ETag(1)},
+		{name: "post / stale", method: http.MethodPost, header: "If-Match", tags: `"stale"`, body: `{"key":"k","value":"v2"}`,
+			want: `{"error": "precondition failed"}`, wantStatus: http.StatusPreconditionFailed},
+		{name: "post / matched", method: http.MethodPost, header: "If-Match", tags: `"stale", ` + databases.ETag(1), body: `{"key":"k","value":"v2"}`,
+			want: `{"key":"k","value":"v2"}`, wantStatus: http.StatusOK, wantETag: databases.ETag(2)},
+		{name: "post / written back", method: http.MethodPost, body: `{"key":"k","value":"v1"}`,
+			want: `{"key":"k","value":"v1"}`, wantStatus: http.StatusOK, wantETag: databases.ETag(3)},
+		{name: "post / stale after written back", method: http.MethodPost, header: "If-Match", tags: databases.ETag(1), body: `{"key":"k","value":"v3"}`,
+			want: `{"error": "precondition failed"}`, wantStatus: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/redis?key=k", bytes.NewBufferString(tt.body))
+			r.Header.Add("Content-Type", "application/json")
+			if tt.header != "" {
+				r.Header.Add(tt.header, tt.tags)
+			}
+			rw := httptest.NewRecorder()
+			NewRedisHandler(client).ServeHTTP(rw, r)
+			if rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %s, want %s", rw.Body.String(), tt.want)
+			}
+			if rw.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", rw.Code, tt.wantStatus)
+			}
+			if got := rw.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ServeHTTP() ETag = %s, want %s", got, tt.wantETag)
+			}
 		})
 	}
 }
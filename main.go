@@ -13,12 +13,15 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// timezones of mongodb filters must be loadable on images without tzdata
+	_ "time/tzdata"
 )
 
 //
 
 func main() {
 	cfgFile := flag.String("config", "./config.json", "absolute or relative path of configuration file")
+	checkIndexes := flag.Bool("check-indexes", false, "compare mongodb indexes with configuration and exit, non-zero exit code on drift")
 	flag.Parse()
 
 	if cfgFile == nil || *cfgFile == "" {
@@ -29,16 +32,52 @@ func main() {
 	if err != nil {
 		log.Fatalf("error while parsing configuration file: %s", err.Error())
 	}
-	var mongoConnection databases.MongoClient
+	// mongodb datasets by name, first one is also served under /mongodb
+	mongoConnections := map[string]databases.MongoClient{}
+	mongoConfigs := map[string]*databases.Database{}
+	var mongoDatasets []string
+	var rollups []rollupRunner
+	rollupKeys := map[string]bool{}
 	var redisConnection *databases.RedisConnection
 	var inmemoryConnection databases.Inmemory
-	// initialize database connections
+	indexDrift := false
+	// indexes of datasets sharing a collection are shared, so none of them
+	// reports indexes of the others as extra
+	collectionIndexes := map[string][]*databases.Index{}
+	for _, db := range cfg.Databases {
+		if db.Type == "mongodb" {
+			collectionIndexes[db.CollectionKey()] = append(collectionIndexes[db.CollectionKey()], db.Indexes...)
+		}
+	}
+	// initialize database connections, only mongodb ones when checking indexes
 	for idx := range cfg.Databases {
+		if *checkIndexes && cfg.Databases[idx].Type != "mongodb" {
+			continue
+		}
 		switch cfg.Databases[idx].Type {
 		case "mongodb":
-			if mongoConnection, err = databases.InitializeMongodb(cfg.Databases[idx]); err != nil {
+			name := cfg.Databases[idx].DatasetName()
+			if _, ok := mongoConnections[name]; ok {
+				log.Fatalf("duplicate mongodb dataset: %s", name)
+			}
+			conn, err := databases.InitializeMongodb(cfg.Databases[idx])
+			if err != nil {
 				log.Fatalf("can't connect to mongodb: %s", err.Error())
 			}
+			shared := collectionIndexes[cfg.Databases[idx].CollectionKey()]
+			drift, err := reconcileIndexes(name, conn, *checkIndexes, shared)
+			if err != nil {
+				log.Fatalf("can't reconcile mongodb indexes of %s: %s", name, err.Error())
+			}
+			indexDrift = indexDrift || drift
+			mongoConnections[name] = conn
+			mongoConfigs[name] = cfg.Databases[idx]
+			// datasets sharing a rollup refresh it once
+			if key := cfg.Databases[idx].RollupKey(); key != "" && !rollupKeys[key] {
+				rollupKeys[key] = true
+				rollups = append(rollups, conn)
+			}
+			mongoDatasets = append(mongoDatasets, name)
 		case "inmemory":
 			if inmemoryConnection, err = databases.InitializeInmemory(cfg.Databases[idx]); err != nil {
 				log.Fatalf("can't initialize in memorydb: %s", err.Error())
@@ -49,22 +88,51 @@ func main() {
 			}
 		}
 	}
+	if *checkIndexes {
+		if indexDrift {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	// caches are wrapped after all connections are ready, redis may be
+	// configured after mongodb datasets
+	for _, name := range mongoDatasets {
+		cache := mongoConfigs[name].Cache
+		if cache == nil {
+			continue
+		}
+		var store databases.ResultCache
+		switch cache.Store {
+		case databases.CacheStoreRedis:
+			if redisConnection == nil {
+				log.Fatalf("redis cache of mongodb dataset %s needs a redis database", name)
+			}
+			store = databases.NewRedisCache(redisConnection)
+		case databases.CacheStoreInmemory:
+			store = databases.NewInmemoryCache()
+		}
+		mongoConnections[name] = databases.NewCachedMongo(mongoConnections[name], store, name, time.Duration(cache.TTL)*time.Second)
+	}
+	// rollups are refreshed in background until shutdown
+	rollupCtx, stopRollups := context.WithCancel(context.Background())
+	defer stopRollups()
+	for _, r := range rollups {
+		go r.RunRollup(rollupCtx)
+	}
 	fmt.Printf("%+v", inmemoryConnection)
 	// parse application flags
 	// create http mux from std lib of go
-	mux := http.NewServeMux()
-	mux.Handle("/mongodb/records", handlers.NewMongodbHandler(mongoConnection))
-	mux.Handle("/redis", handlers.NewRedisHandler(redisConnection))
-
-	//  inmemory term is not clear in case file
-	//  as any in memory service like redis, memcache etc or in memory structure in application.
-	//  so i use sync map for in memory local storage also implement same functionality with redis too
-	mux.Handle("/inmemory", handlers.NewInmemoryHandler(inmemoryConnection))
+	// long lived event streams are closed on shutdown, server waits for
+	// other requests to finish
+	streams, stopStreams := context.WithCancel(context.Background())
+	defer stopStreams()
+	mux := newServeMux(streams, mongoConnections, mongoDatasets, redisConnection, inmemoryConnection)
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", cfg.Application.Host, cfg.Application.Port),
 		Handler: mux,
 	}
+	server.RegisterOnShutdown(stopStreams)
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
@@ -83,6 +151,7 @@ func main() {
 
 	<-stop
 	fmt.Println("")
+	stopRollups()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
@@ -97,3 +166,77 @@ func main() {
 	log.Println("[Shutdown] closed")
 
 }
+
+// newServeMux registers endpoints of configured databases, redis and
+// inmemory connections are nil when they are not configured
+func newServeMux(shutdown context.Context, mongoConnections map[string]databases.MongoClient, mongoDatasets []string,
+	redisConnection *databases.RedisConnection, inmemoryConnection databases.Inmemory) *http.ServeMux {
+	if inmemoryConnection == nil {
+		inmemoryConnection = databases.NewInmemory()
+	}
+	mux := http.NewServeMux()
+	for _, name := range mongoDatasets {
+		mountMongodb(shutdown, mux, "/mongodb/"+name, mongoConnections[name])
+	}
+	if len(mongoDatasets) > 0 {
+		mountMongodb(shutdown, mux, "/mongodb", mongoConnections[mongoDatasets[0]])
+	}
+	mux.Handle("/redis", handlers.NewRedisHandler(redisConnection))
+	mux.Handle("/redis/keys", handlers.NewKeysHandler(redisConnection))
+	mux.Handle("/redis/batch/", handlers.NewRedisBatchHandler(redisConnection))
+	mux.Handle("/redis/incr", handlers.NewIncrHandler(redisConnection))
+
+	//  inmemory term is not clear in case file
+	//  as any in memory service like redis, memcache etc or in memory structure in application.
+	//  so i use sync map for in memory local storage also implement same functionality with redis too
+	mux.Handle("/inmemory", handlers.NewInmemoryHandler(inmemoryConnection))
+	mux.Handle("/inmemory/keys", handlers.NewKeysHandler(inmemoryConnection))
+	mux.Handle("/inmemory/batch/", handlers.NewInmemoryBatchHandler(inmemoryConnection))
+	mux.Handle("/inmemory/incr", handlers.NewIncrHandler(inmemoryConnection))
+
+	return mux
+}
+
+// mountMongodb registers endpoints of a mongodb dataset under prefix, event
+// streams are closed when shutdown is done
+func mountMongodb(shutdown context.Context, mux *http.ServeMux, prefix string, c databases.MongoClient) {
+	mux.Handle(prefix+"/records", handlers.NewMongodbHandler(c))
+	mux.Handle(prefix+"/records/ingest", handlers.NewMongodbIngestHandler(c))
+	mux.Handle(prefix+"/records/explain", handlers.NewMongodbExplainHandler(c))
+	mux.Handle(prefix+"/records/events", handlers.NewMongodbEventsHandler(shutdown, c))
+}
+
+type rollupRunner interface {
+	RunRollup(context.Context)
+}
+
+type indexReconciler interface {
+	ReconcileIndexes(context.Context, bool, []*databases.Index) (*databases.IndexReport, error)
+}
+
+// reconcileIndexes creates missing indexes of a mongodb dataset, or only
+// reports differences when checkOnly is set, returns whether indexes drifted.
+// Shared are indexes of all datasets of the collection.
+func reconcileIndexes(name string, c indexReconciler, checkOnly bool, shared []*databases.Index) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	report, err := c.ReconcileIndexes(ctx, checkOnly, shared)
+	if err != nil {
+		return false, err
+	}
+	for _, index := range report.Created {
+		log.Printf("[Indexes] %s: created %s", name, index)
+	}
+	if checkOnly {
+		for _, index := range report.Missing {
+			log.Printf("[Indexes] %s: missing %s", name, index)
+		}
+	}
+	for _, index := range report.Changed {
+		log.Printf("[Indexes] %s: %s differs from configuration", name, index)
+	}
+	for _, index := range report.Extra {
+		log.Printf("[Indexes] %s: %s is not in configuration", name, index)
+	}
+	return report.Drift(), nil
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2023 Timu Eren
+//
+// This file is part of getir-case.
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"getircase/databases"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_newServeMux(t *testing.T) {
+	// neither redis nor inmemory configured, their endpoints must not panic
+	mux := newServeMux(context.Background(), map[string]databases.MongoClient{}, nil, nil, nil)
+
+	inmemoryErr := `{"error": "inmemory: initialize inmemory first"}`
+	redisErr := `{"error": "redis: initialize redis first"}`
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{name: "inmemory", method: http.MethodGet, path: "/inmemory?key=a", want: inmemoryErr},
+		{name: "inmemory batch", method: http.MethodPost, path: "/inmemory/batch/get", body: `[{"key":"a"}]`, want: inmemoryErr},
+		{name: "inmemory keys", method: http.MethodGet, path: "/inmemory/keys", want: inmemoryErr},
+		{name: "inmemory incr", method: http.MethodPost, path: "/inmemory/incr", body: `{"key":"a"}`, want: inmemoryErr},
+		{name: "redis", method: http.MethodGet, path: "/redis?key=a", want: redisErr},
+		{name: "redis batch", method: http.MethodPost, path: "/redis/batch/get", body: `[{"key":"a"}]`, want: redisErr},
+		{name: "redis keys", method: http.MethodGet, path: "/redis/keys", want: redisErr},
+		{name: "redis incr", method: http.MethodPost, path: "/redis/incr", body: `{"key":"a"}`, want: redisErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			r.Header.Add("Content-Type", "application/json")
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, r)
+			if rw.Code != http.StatusInternalServerError || rw.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %d %s, want %d %s", rw.Code, rw.Body.String(), http.StatusInternalServerError, tt.want)
+			}
+		})
+	}
+}